@@ -0,0 +1,173 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// agent is the Metric subsystem we use.
+	agentSubsystem = "agent"
+)
+
+// Metric descriptors.
+var (
+	AgentPoolInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, agentSubsystem, "pool_info"),
+		"Information about existing agent pools",
+		[]string{"organization", "id", "name"}, nil,
+	)
+	Agents = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "agents"),
+		"Number of agents of the agent pool by status",
+		[]string{"organization", "pool", "status"}, nil,
+	)
+	AgentLastPing = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, agentSubsystem, "last_ping_timestamp_seconds"),
+		"Unix timestamp of the last time the agent pinged Terraform Cloud",
+		[]string{"organization", "pool", "id", "name"}, nil,
+	)
+
+	// agentStatuses are always sent, so that a pool without idle agents reports 0 instead of nothing.
+	agentStatuses = []string{"idle", "busy", "errored", "exited", "unknown"}
+)
+
+// agent is an agent of a pool, as listed by the Agents API.
+type agent struct {
+	ID         string    `jsonapi:"primary,agents"`
+	Name       string    `jsonapi:"attr,name"`
+	Status     string    `jsonapi:"attr,status"`
+	LastPingAt time.Time `jsonapi:"attr,last-ping-at,iso8601"`
+}
+
+// ScrapeAgents scrapes metrics about the agent pools and their agents.
+type ScrapeAgents struct{}
+
+func init() {
+	Scrapers[ScrapeAgents{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeAgents) Name() string {
+	return "agents"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeAgents) Help() string {
+	return "Scrape information from the Agent Pools and Agents APIs: https://www.terraform.io/docs/cloud/api/agents.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeAgents) Version() string {
+	return "v2"
+}
+
+// listAgentPools pages through the Agent Pools API and returns every agent pool of an organization.
+func listAgentPools(ctx context.Context, organization string, config *setup.Config) ([]*tfe.AgentPool, error) {
+	pools := []*tfe.AgentPool{}
+	for page := 1; ; page++ {
+		poolsList, err := config.Client.AgentPools.List(ctx, organization, tfe.AgentPoolListOptions{
+			ListOptions: tfe.ListOptions{
+				PageSize:   config.PageSize,
+				PageNumber: page,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%v, (organization=%s, page=%d)", err, organization, page)
+		}
+
+		pools = append(pools, poolsList.Items...)
+		if poolsList.Pagination == nil || page >= poolsList.Pagination.TotalPages {
+			return pools, nil
+		}
+	}
+}
+
+// listAgents pages through the Agents API and returns every agent of an agent pool.
+func listAgents(ctx context.Context, poolID string, config *setup.Config) ([]*agent, error) {
+	agents := []*agent{}
+	for page := 1; ; page++ {
+		items, pagination, err := listAPI(ctx, config, fmt.Sprintf("agent-pools/%s/agents", poolID), pageQuery(page, config), reflect.TypeOf(&agent{}))
+		if err != nil {
+			return nil, fmt.Errorf("%v, (agent_pool=%s, page=%d)", err, poolID, page)
+		}
+
+		for _, item := range items {
+			agents = append(agents, item.(*agent))
+		}
+		if page >= pagination.TotalPages {
+			return agents, nil
+		}
+	}
+}
+
+func getAgentPool(ctx context.Context, organization string, pool *tfe.AgentPool, config *setup.Config, ch chan<- prometheus.Metric) error {
+	agents, err := listAgents(ctx, pool.ID, config)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int, len(agentStatuses))
+	for _, status := range agentStatuses {
+		counts[status] = 0
+	}
+	metrics := []prometheus.Metric{
+		prometheus.MustNewConstMetric(AgentPoolInfo, prometheus.GaugeValue, 1, organization, pool.ID, pool.Name),
+	}
+	for _, a := range agents {
+		if _, ok := counts[a.Status]; ok {
+			counts[a.Status]++
+		} else {
+			counts["unknown"]++
+		}
+		if !a.LastPingAt.IsZero() {
+			metrics = append(metrics, prometheus.MustNewConstMetric(AgentLastPing, prometheus.GaugeValue, timestampSeconds(a.LastPingAt), organization, pool.Name, a.ID, a.Name))
+		}
+	}
+	for _, status := range agentStatuses {
+		metrics = append(metrics, prometheus.MustNewConstMetric(Agents, prometheus.GaugeValue, float64(counts[status]), organization, pool.Name, status))
+	}
+
+	for _, m := range metrics {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+func (ScrapeAgents) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, name := range config.Organizations {
+		name := name
+		g.Go(func() error {
+			pools, err := listAgentPools(ctx, name, config)
+			if err != nil {
+				return err
+			}
+
+			for _, pool := range pools {
+				if err := getAgentPool(ctx, name, pool, config, ch); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrapeAgents(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/organizations/test-org/agent-pools":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":1}
+				},
+				"data":[{
+					"id":"apool-1",
+					"type":"agent-pools",
+					"attributes":{"name":"on-prem"},
+					"relationships":{"organization":{"data":{"id":"test-org","type":"organizations"}}}
+				}]
+			}`))
+		case "/api/v2/agent-pools/apool-1/agents":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":3}
+				},
+				"data":[{
+					"id":"agent-1",
+					"type":"agents",
+					"attributes":{"name":"host-1","status":"busy","last-ping-at":"2020-10-10T10:10:10.101Z"}
+				}, {
+					"id":"agent-2",
+					"type":"agents",
+					"attributes":{"name":"host-2","status":"busy","last-ping-at":"2020-10-10T10:10:10.101Z"}
+				}, {
+					"id":"agent-3",
+					"type":"agents",
+					"attributes":{"name":"host-3","status":"exited","last-ping-at":"2020-10-10T10:10:10.101Z"}
+				}]
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}},
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		defer close(ch)
+		if err = (ScrapeAgents{}).Scrape(context.Background(), config, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+	}()
+
+	counterExpected := []MetricResult{
+		{labels: labelMap{"organization": "test-org", "id": "apool-1", "name": "on-prem"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "pool": "on-prem", "id": "agent-1", "name": "host-1"}, value: 1602324610.101, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "pool": "on-prem", "id": "agent-2", "name": "host-2"}, value: 1602324610.101, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "pool": "on-prem", "id": "agent-3", "name": "host-3"}, value: 1602324610.101, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "pool": "on-prem", "status": "idle"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "pool": "on-prem", "status": "busy"}, value: 2, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "pool": "on-prem", "status": "errored"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "pool": "on-prem", "status": "exited"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "pool": "on-prem", "status": "unknown"}, value: 0, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range counterExpected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+	"github.com/svanharmelen/jsonapi"
+)
+
+// The go-tfe release we build against does not model every attribute or endpoint we export,
+// the helpers below call the Terraform API directly using the same address, token and
+// HTTP client as config.Client and decode the JSON:API responses with the same library.
+
+// errForbidden is returned when the API token is valid but not allowed to access the resource.
+var errForbidden = errors.New("forbidden")
+
+func newAPIRequest(ctx context.Context, config *setup.Config, path string, query url.Values) (*http.Request, error) {
+	u, err := url.Parse(config.ClientConfig.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address: %v", err)
+	}
+	basePath := config.ClientConfig.BasePath
+	if basePath == "" {
+		basePath = tfe.DefaultBasePath
+	}
+	u.Path = strings.TrimSuffix(basePath, "/") + "/" + path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.api+json")
+	req.Header.Set("Authorization", "Bearer "+config.ClientConfig.Token)
+
+	return req, nil
+}
+
+func doAPIRequest(ctx context.Context, config *setup.Config, path string, query url.Values) (*http.Response, error) {
+	req, err := newAPIRequest(ctx, config, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := config.ClientConfig.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		resp.Body.Close()
+		return nil, tfe.ErrUnauthorized
+	case resp.StatusCode == http.StatusForbidden:
+		resp.Body.Close()
+		return nil, errForbidden
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, tfe.ErrResourceNotFound
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, path)
+	}
+
+	return resp, nil
+}
+
+// readAPI decodes a single JSON:API resource into v, which must be a pointer to a struct with jsonapi tags.
+func readAPI(ctx context.Context, config *setup.Config, path string, query url.Values, v interface{}) error {
+	resp, err := doAPIRequest(ctx, config, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return jsonapi.UnmarshalPayload(resp.Body, v)
+}
+
+// listAPI decodes one page of a JSON:API collection into a slice of model, which must be a pointer to a struct type.
+func listAPI(ctx context.Context, config *setup.Config, path string, query url.Values, model reflect.Type) ([]interface{}, *tfe.Pagination, error) {
+	return listAPIRaw(ctx, config, path, query, model, nil)
+}
+
+// listAPIRaw is listAPI that also decodes the whole document into raw with encoding/json, when not nil,
+// for the members jsonapi can not map, e.g. relationships to resources of more than one type.
+func listAPIRaw(ctx context.Context, config *setup.Config, path string, query url.Values, model reflect.Type, raw interface{}) ([]interface{}, *tfe.Pagination, error) {
+	resp, err := doAPIRequest(ctx, config, path, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body := bytes.NewBuffer(nil)
+	items, err := jsonapi.UnmarshalManyPayload(io.TeeReader(resp.Body, body), model)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var meta struct {
+		Meta struct {
+			Pagination tfe.Pagination `json:"pagination"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &meta); err != nil {
+		return nil, nil, err
+	}
+	if raw != nil {
+		if err := json.Unmarshal(body.Bytes(), raw); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return items, &meta.Meta.Pagination, nil
+}
+
+func pageQuery(page int, config *setup.Config) url.Values {
+	query := url.Values{"page[number]": []string{strconv.Itoa(page)}}
+	if config.PageSize > 0 {
+		query.Set("page[size]", strconv.Itoa(config.PageSize))
+	}
+	return query
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cache runs the Exporter scrapers in the background on a fixed interval and serves the metrics
+// of the last successful cycle. It implements the prometheus.Collector interface.
+type Cache struct {
+	exporter *Exporter
+	interval time.Duration
+
+	mu       sync.RWMutex
+	snapshot []prometheus.Metric
+}
+
+// NewCache returns a new Cache for the provided Config, call Run to start polling the Terraform API.
+func NewCache(config setup.Config, metrics Metrics, interval time.Duration) *Cache {
+	return &Cache{
+		exporter: New(context.Background(), config, metrics),
+		interval: interval,
+	}
+}
+
+// Run refreshes the snapshot immediately and then on every interval until ctx is cancelled.
+func (c *Cache) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.refresh(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refresh runs a single scrape cycle and replaces the snapshot only if every scraper succeeded.
+func (c *Cache) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.interval)
+	defer cancel()
+
+	ch := make(chan prometheus.Metric)
+	done := make(chan bool, 1)
+	go func() {
+		done <- c.exporter.scrape(ctx, ch)
+		close(ch)
+	}()
+
+	snapshot := []prometheus.Metric{}
+	for m := range ch {
+		snapshot = append(snapshot, m)
+	}
+
+	if !<-done {
+		level.Warn(c.exporter.logger).Log("msg", "Background scrape failed, keeping previous metrics")
+		return
+	}
+
+	c.mu.Lock()
+	c.snapshot = snapshot
+	c.mu.Unlock()
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *Cache) Describe(ch chan<- *prometheus.Desc) {
+	c.exporter.metrics.describe(ch, c.exporter.discovery())
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *Cache) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	for _, m := range c.snapshot {
+		ch <- m
+	}
+	c.mu.RUnlock()
+
+	c.exporter.metrics.collect(ch, c.exporter.discovery())
+}
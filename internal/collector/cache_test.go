@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	"github.com/go-kit/kit/log"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestCacheKeepsSnapshotOnError(t *testing.T) {
+	var failing int32
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 && r.URL.Path != "/api/v2/ping" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{
+			"data": {
+				"id":"test-org",
+				"type":"organizations",
+				"attributes": {"created-at":"1010-10-10T10:10:10.101Z"}
+			}
+		}`))
+	}))
+	defer mockAPI.Close()
+
+	client, err := tfe.NewClient(&tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	})
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := setup.Config{
+		Client: *client,
+		CLI:    setup.CLI{Organizations: []string{"test-org"}},
+		Logger: log.NewNopLogger(),
+	}
+
+	metrics := NewMetrics()
+	cache := NewCache(config, metrics, time.Minute)
+	cache.exporter.scrapers = []Scraper{ScrapeOrganizations{}}
+
+	collect := func() (int, float64) {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			defer close(ch)
+			cache.Collect(ch)
+		}()
+		infos := 0
+		for m := range ch {
+			if m.Desc() == OrganizationsInfo {
+				infos++
+			}
+		}
+		pb := &dto.Metric{}
+		metrics.Error.Write(pb)
+		return infos, pb.GetGauge().GetValue()
+	}
+
+	convey.Convey("Cache snapshots", t, func() {
+		cache.refresh(context.Background())
+		infos, scrapeError := collect()
+		convey.So(infos, convey.ShouldEqual, 1)
+		convey.So(scrapeError, convey.ShouldEqual, 0)
+
+		atomic.StoreInt32(&failing, 1)
+		cache.refresh(context.Background())
+		infos, scrapeError = collect()
+		convey.So(infos, convey.ShouldEqual, 1)
+		convey.So(scrapeError, convey.ShouldEqual, 1)
+	})
+}
@@ -3,7 +3,9 @@ package collector
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
@@ -11,8 +13,6 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 
-	tfe "github.com/hashicorp/go-tfe"
-
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -34,14 +34,41 @@ type Exporter struct {
 
 // Metrics represents exporter metrics which values can be carried between http requests.
 type Metrics struct {
-	TotalScrapes prometheus.Counter
-	ScrapeErrors *prometheus.CounterVec
-	Error        prometheus.Gauge
+	TotalScrapes            prometheus.Counter
+	ScrapeErrors            *prometheus.CounterVec
+	Error                   prometheus.Gauge
+	LastSuccessfulScrape    prometheus.Gauge
+	CollectorEnabled        *prometheus.GaugeVec
+	OrganizationsDiscovered prometheus.Gauge
+
+	// organizations caches the discovered organizations between scrapes.
+	organizations *organizationsCache
+	// runs accumulates the runs counted by the runs collector between scrapes.
+	runs *runTotals
+	// runQueue accumulates the queue wait of the runs listed by the run_queue collector between scrapes.
+	runQueue *runQueueWaits
+}
+
+// metricsKey is the context key under which an Exporter passes its Metrics to the scrapers.
+type metricsKey struct{}
+
+// withMetrics returns a copy of ctx carrying metrics, so that scrapers keep their state per Exporter and target.
+func withMetrics(ctx context.Context, metrics Metrics) context.Context {
+	return context.WithValue(ctx, metricsKey{}, metrics)
+}
+
+// metricsFrom returns the Metrics of the Exporter running the scrape, scrapes run outside of an Exporter
+// start from empty state.
+func metricsFrom(ctx context.Context) Metrics {
+	if metrics, ok := ctx.Value(metricsKey{}).(Metrics); ok {
+		return metrics
+	}
+	return NewMetrics()
 }
 
 var (
-	// scrapers lists all possible collection methods.
-	Scrapers = []Scraper{}
+	// Scrapers lists all possible collection methods and whether they are enabled by default.
+	Scrapers = map[Scraper]bool{}
 	// Metric descriptors.
 	scrapeDurationDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, exporter, "collector_duration_seconds"),
@@ -50,67 +77,113 @@ var (
 	)
 )
 
+// Collectors describes every registered scraper so the CLI can generate flags to toggle them.
+func Collectors() []setup.Collector {
+	collectors := []setup.Collector{}
+	for scraper, enabledByDefault := range Scrapers {
+		collectors = append(collectors, setup.Collector{
+			Name:    scraper.Name(),
+			Help:    scraper.Help(),
+			Enabled: enabledByDefault,
+		})
+	}
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].Name < collectors[j].Name })
+	return collectors
+}
+
 // New returns a new Terraform API exporter for the provided Config.
 func New(ctx context.Context, config setup.Config, metrics Metrics) *Exporter {
+	scrapers := []Scraper{}
+	for scraper, enabledByDefault := range Scrapers {
+		enabled, ok := config.Collectors[scraper.Name()]
+		if !ok {
+			enabled = enabledByDefault
+		}
+
+		if enabled {
+			scrapers = append(scrapers, scraper)
+			metrics.CollectorEnabled.WithLabelValues(scraper.Name()).Set(1)
+		} else {
+			metrics.CollectorEnabled.WithLabelValues(scraper.Name()).Set(0)
+		}
+	}
+
 	return &Exporter{
 		ctx:      ctx,
 		logger:   config.Logger,
 		config:   config,
-		scrapers: Scrapers,
+		scrapers: scrapers,
 		metrics:  metrics,
 	}
 }
 
 // Describe implements the prometheus.Collector interface.
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	ch <- e.metrics.TotalScrapes.Desc()
-	ch <- e.metrics.Error.Desc()
-	e.metrics.ScrapeErrors.Describe(ch)
+	e.metrics.describe(ch, e.discovery())
 }
 
 // Collect implements the prometheus.Collector interface.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	e.scrape(e.ctx, ch)
+	e.metrics.collect(ch, e.discovery())
+}
 
-	ch <- e.metrics.TotalScrapes
-	ch <- e.metrics.Error
-	e.metrics.ScrapeErrors.Collect(ch)
+// discovery reports whether the organizations are discovered through the API, as none are configured.
+func (e *Exporter) discovery() bool {
+	return len(e.config.Organizations) == 0
 }
 
-func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) {
+// scrape runs all scrapers concurrently and reports whether all of them succeeded.
+func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) bool {
 	e.metrics.TotalScrapes.Inc()
-	if len(e.config.Organizations) == 0 {
-		// Note: At some point this will return a paginated response.
-		oo, err := e.config.Client.Organizations.List(ctx, tfe.OrganizationListOptions{})
-		if err != nil {
+
+	// The organizations are resolved once per scrape cycle, all scrapers of the cycle receive the same
+	// copy of the config and must treat it as read-only.
+	config := e.config
+	if e.discovery() {
+		organizations, err := e.metrics.organizations.get(ctx, &config)
+		if err != nil && organizations == nil {
 			e.metrics.Error.Set(1)
 			level.Error(e.logger).Log("msg", "Unable to List Organizations", "err", err)
-			return
+			return false
 		}
-
-		for _, o := range oo.Items {
-			e.config.Organizations = append(e.config.Organizations, o.Name)
+		if err != nil {
+			level.Warn(e.logger).Log("msg", "Unable to List Organizations, scraping the previously discovered ones", "err", err)
 		}
+
+		config.Organizations = organizations
+		e.metrics.OrganizationsDiscovered.Set(float64(len(organizations)))
 	}
 
-	e.metrics.Error.Set(0)
+	ctx = withMetrics(ctx, e.metrics)
+	ctx = withPageWorkers(ctx, config.PageWorkers)
 
+	var failed int32
 	var wg sync.WaitGroup
-	defer wg.Wait()
 	for _, scraper := range e.scrapers {
 		wg.Add(1)
 		go func(scraper Scraper) {
 			defer wg.Done()
 			label := "collect." + scraper.Name()
 			scrapeTime := time.Now()
-			if err := scraper.Scrape(ctx, &e.config, ch); err != nil {
+			if err := scraper.Scrape(ctx, &config, ch); err != nil {
 				level.Error(e.logger).Log("msg", "Error from scraper", "scraper", scraper.Name(), "err", err)
 				e.metrics.ScrapeErrors.WithLabelValues(label).Inc()
-				e.metrics.Error.Set(1)
+				atomic.StoreInt32(&failed, 1)
 			}
 			ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), label)
 		}(scraper)
 	}
+	wg.Wait()
+
+	if atomic.LoadInt32(&failed) != 0 {
+		e.metrics.Error.Set(1)
+		return false
+	}
+
+	e.metrics.Error.Set(0)
+	e.metrics.LastSuccessfulScrape.SetToCurrentTime()
+	return true
 }
 
 // NewMetrics creates new Metrics instance.
@@ -134,5 +207,70 @@ func NewMetrics() Metrics {
 			Name:      "last_scrape_error",
 			Help:      "Whether the last scrape of metrics from Terraform API resulted in an error (1 for error, 0 for success).",
 		}),
+		LastSuccessfulScrape: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "last_successful_scrape_timestamp_seconds",
+			Help:      "Unix timestamp of the last scrape of metrics from Terraform API that completed without errors.",
+		}),
+		CollectorEnabled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "collector_enabled",
+			Help:      "Whether a collector is enabled (1 for enabled, 0 for disabled).",
+		}, []string{"collector"}),
+		OrganizationsDiscovered: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "organizations_discovered",
+			Help:      "Number of organizations visible to the API token, when no organizations are configured.",
+		}),
+		organizations: &organizationsCache{},
+		runs:          newRunTotals(),
+		runQueue:      newRunQueueWaits(),
 	}
 }
+
+// describe sends the descriptors of the exporter metrics, OrganizationsDiscovered is only described with discovery.
+func (m Metrics) describe(ch chan<- *prometheus.Desc, discovery bool) {
+	ch <- m.TotalScrapes.Desc()
+	ch <- m.Error.Desc()
+	ch <- m.LastSuccessfulScrape.Desc()
+	if discovery {
+		ch <- m.OrganizationsDiscovered.Desc()
+	}
+	m.ScrapeErrors.Describe(ch)
+	m.CollectorEnabled.Describe(ch)
+}
+
+// collect sends the exporter metrics, OrganizationsDiscovered is only sent with discovery.
+func (m Metrics) collect(ch chan<- prometheus.Metric, discovery bool) {
+	ch <- m.TotalScrapes
+	ch <- m.Error
+	ch <- m.LastSuccessfulScrape
+	if discovery {
+		ch <- m.OrganizationsDiscovered
+	}
+	m.ScrapeErrors.Collect(ch)
+	m.CollectorEnabled.Collect(ch)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// boolToFloat returns 1 for true and 0 for false, the values of boolean gauges.
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// timestampSeconds converts t to the Unix timestamp in seconds Prometheus expects for *_timestamp_seconds metrics.
+func timestampSeconds(t time.Time) float64 {
+	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
+}
@@ -1,8 +1,24 @@
 package collector
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	"github.com/go-kit/kit/log"
+
+	tfe "github.com/hashicorp/go-tfe"
+
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
 )
 
 type labelMap map[string]string
@@ -26,8 +42,125 @@ func readMetric(m prometheus.Metric) MetricResult {
 	if pb.Counter != nil {
 		return MetricResult{labels: labels, value: pb.GetCounter().GetValue(), metricType: dto.MetricType_COUNTER}
 	}
+	if pb.Histogram != nil {
+		return MetricResult{labels: labels, value: pb.GetHistogram().GetSampleSum(), metricType: dto.MetricType_HISTOGRAM}
+	}
 	if pb.Untyped != nil {
 		return MetricResult{labels: labels, value: pb.GetUntyped().GetValue(), metricType: dto.MetricType_UNTYPED}
 	}
 	panic("Unsupported metric type")
 }
+
+func TestHandlerConcurrentScrapes(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		switch {
+		case r.URL.Path == "/api/v2/organizations":
+			w.Write([]byte(`{
+				"meta":{"pagination":{"current-page":1,"total-pages":1,"total-count":2}},
+				"data":[
+					{"id":"org-1","type":"organizations","attributes":{}},
+					{"id":"org-2","type":"organizations","attributes":{}}
+				]
+			}`))
+		case strings.HasSuffix(r.URL.Path, "/workspaces"):
+			org := strings.Split(r.URL.Path, "/")[4]
+			w.Write([]byte(fmt.Sprintf(`{
+				"meta":{"pagination":{"current-page":1,"total-pages":1,"total-count":1}},
+				"data":[{
+					"id":"ws-%s",
+					"type":"workspaces",
+					"attributes":{"name":"dev"},
+					"relationships":{"organization":{"data":{"id":"%s","type":"organizations"}}}
+				}]
+			}`, org, org)))
+		case strings.HasPrefix(r.URL.Path, "/api/v2/organizations/"):
+			org := strings.Split(r.URL.Path, "/")[4]
+			w.Write([]byte(fmt.Sprintf(`{"data":{"id":"%s","type":"organizations","attributes":{}}}`, org)))
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	// No organizations are configured, so every request goes through discovery.
+	handler := NewHandler(NewMetrics(), setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		Logger:       log.NewNopLogger(),
+	})
+
+	var wg sync.WaitGroup
+	bodies := make(chan string, 50)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				rec := httptest.NewRecorder()
+				handler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+				bodies <- rec.Body.String()
+			}
+		}()
+	}
+	wg.Wait()
+	close(bodies)
+
+	convey.Convey("Every concurrent scrape exports each organization once", t, func() {
+		for body := range bodies {
+			convey.So(strings.Count(body, "tf_organizations_info{"), convey.ShouldEqual, 2)
+			convey.So(strings.Count(body, "tf_workspaces_info{"), convey.ShouldEqual, 2)
+			convey.So(body, convey.ShouldContainSubstring, "tf_exporter_organizations_discovered 2")
+		}
+	})
+}
+
+func TestHandlerScrapeTimeout(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/ping" {
+			return
+		}
+		// Never answer before the scrape gives up.
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	handler := NewHandler(NewMetrics(), setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}},
+		Logger:       log.NewNopLogger(),
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "0.2")
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	handler(rec, req)
+
+	convey.Convey("The scrape is cancelled once the Prometheus timeout expires", t, func() {
+		convey.So(time.Since(start), convey.ShouldBeLessThan, 2*time.Second)
+		convey.So(rec.Body.String(), convey.ShouldContainSubstring, "tf_exporter_last_scrape_error 1")
+		// The organizations are configured, none are discovered.
+		convey.So(rec.Body.String(), convey.ShouldNotContainSubstring, "tf_exporter_organizations_discovered")
+	})
+}
@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// cost_estimate is the Metric subsystem we use.
+	costEstimateSubsystem = "cost_estimate"
+)
+
+// Metric descriptors.
+var (
+	CostEstimateMonthlyCost = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, costEstimateSubsystem, "monthly_cost_dollars"),
+		"Proposed monthly cost estimated for the current run of the workspace",
+		[]string{"organization", "workspace"}, nil,
+	)
+	CostEstimateDeltaMonthlyCost = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, costEstimateSubsystem, "delta_monthly_cost_dollars"),
+		"Difference between the proposed and the prior monthly cost estimated for the current run of the workspace",
+		[]string{"organization", "workspace"}, nil,
+	)
+	CostEstimateResources = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, costEstimateSubsystem, "resources"),
+		"Number of resources of the current run of the workspace the cost estimation could (matched) or could not (unmatched) price",
+		[]string{"organization", "workspace", "state"}, nil,
+	)
+	CostEstimateOrganizationMonthlyCost = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, costEstimateSubsystem, "organization_monthly_cost_dollars"),
+		"Sum of the proposed monthly cost estimated for the current runs of the workspaces of the organization",
+		[]string{"organization"}, nil,
+	)
+)
+
+// ScrapeCostEstimates scrapes metrics about the cost estimate of the current run of every workspace.
+type ScrapeCostEstimates struct{}
+
+func init() {
+	Scrapers[ScrapeCostEstimates{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeCostEstimates) Name() string {
+	return "cost_estimates"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeCostEstimates) Help() string {
+	return "Scrape the cost estimates of the current runs from the Cost Estimates API: https://www.terraform.io/docs/cloud/api/cost-estimates.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeCostEstimates) Version() string {
+	return "v2"
+}
+
+// getCostEstimate sends the metrics of the cost estimate of a workspace and returns its proposed monthly cost,
+// ok is false when the estimate is not finished.
+func getCostEstimate(ctx context.Context, w *workspace, config *setup.Config, ch chan<- prometheus.Metric) (cost float64, ok bool, err error) {
+	ce, err := config.Client.CostEstimates.Read(ctx, w.CurrentRun.CostEstimate.ID)
+	if err != nil {
+		return 0, false, fmt.Errorf("%v, (workspace=%s, cost_estimate=%s)", err, w.ID, w.CurrentRun.CostEstimate.ID)
+	}
+	if ce.Status != tfe.CostEstimateFinished {
+		return 0, false, nil
+	}
+
+	proposed, err := strconv.ParseFloat(ce.ProposedMonthlyCost, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid proposed monthly cost %q, (workspace=%s, cost_estimate=%s)", ce.ProposedMonthlyCost, w.ID, ce.ID)
+	}
+	delta, err := strconv.ParseFloat(ce.DeltaMonthlyCost, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid delta monthly cost %q, (workspace=%s, cost_estimate=%s)", ce.DeltaMonthlyCost, w.ID, ce.ID)
+	}
+
+	for _, m := range []prometheus.Metric{
+		prometheus.MustNewConstMetric(CostEstimateMonthlyCost, prometheus.GaugeValue, proposed, w.Organization.Name, w.Name),
+		prometheus.MustNewConstMetric(CostEstimateDeltaMonthlyCost, prometheus.GaugeValue, delta, w.Organization.Name, w.Name),
+		prometheus.MustNewConstMetric(CostEstimateResources, prometheus.GaugeValue, float64(ce.MatchedResourcesCount), w.Organization.Name, w.Name, "matched"),
+		prometheus.MustNewConstMetric(CostEstimateResources, prometheus.GaugeValue, float64(ce.UnmatchedResourcesCount), w.Organization.Name, w.Name, "unmatched"),
+	} {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return 0, false, ctx.Err()
+		}
+	}
+
+	return proposed, true, nil
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+// Every workspace with a cost estimate costs an extra request, which is why the scraper is disabled by default.
+func (ScrapeCostEstimates) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	var mu sync.Mutex
+	totals := map[string]float64{}
+
+	err := scrapeWorkspacesPages(ctx, config, "current_run", func(ctx context.Context, workspaces []*workspace) error {
+		for _, w := range workspaces {
+			if w.CurrentRun == nil || w.CurrentRun.CostEstimate == nil {
+				continue
+			}
+
+			cost, ok, err := getCostEstimate(ctx, w, config, ch)
+			if err != nil {
+				return err
+			}
+			if ok {
+				mu.Lock()
+				totals[w.Organization.Name] += cost
+				mu.Unlock()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	organizations := make([]string, 0, len(totals))
+	for organization := range totals {
+		organizations = append(organizations, organization)
+	}
+	sort.Strings(organizations)
+
+	for _, organization := range organizations {
+		select {
+		case ch <- prometheus.MustNewConstMetric(CostEstimateOrganizationMonthlyCost, prometheus.GaugeValue, totals[organization], organization):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
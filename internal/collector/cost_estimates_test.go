@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrapeCostEstimates(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/organizations/test-org/workspaces":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":3}
+				},
+				"data":[{
+					"id":"test-id-1",
+					"type":"workspaces",
+					"attributes":{"name":"dev"},
+					"relationships":{
+						"organization":{"data":{"id":"test-org","type":"organizations"}},
+						"current-run":{"data":{"id":"run-id-1","type":"runs"}}
+					}
+				}, {
+					"id":"test-id-2",
+					"type":"workspaces",
+					"attributes":{"name":"stg"},
+					"relationships":{
+						"organization":{"data":{"id":"test-org","type":"organizations"}},
+						"current-run":{"data":{"id":"run-id-2","type":"runs"}}
+					}
+				}, {
+					"id":"test-id-3",
+					"type":"workspaces",
+					"attributes":{"name":"prd"},
+					"relationships":{
+						"organization":{"data":{"id":"test-org","type":"organizations"}},
+						"current-run":{"data":{"id":"run-id-3","type":"runs"}}
+					}
+				}],
+				"included":[{
+					"id":"run-id-1",
+					"type":"runs",
+					"attributes":{"status":"applied"},
+					"relationships":{"cost-estimate":{"data":{"id":"ce-1","type":"cost-estimates"}}}
+				}, {
+					"id":"run-id-2",
+					"type":"runs",
+					"attributes":{"status":"applied"},
+					"relationships":{"cost-estimate":{"data":{"id":"ce-2","type":"cost-estimates"}}}
+				}, {
+					"id":"run-id-3",
+					"type":"runs",
+					"attributes":{"status":"planning"},
+					"relationships":{"cost-estimate":{"data":{"id":"ce-3","type":"cost-estimates"}}}
+				}]
+			}`))
+		case "/api/v2/cost-estimates/ce-1":
+			w.Write([]byte(`{"data":{
+				"id":"ce-1",
+				"type":"cost-estimates",
+				"attributes":{
+					"status":"finished",
+					"proposed-monthly-cost":"25.5",
+					"prior-monthly-cost":"20.5",
+					"delta-monthly-cost":"5.0",
+					"matched-resources-count":4,
+					"unmatched-resources-count":1
+				}
+			}}`))
+		case "/api/v2/cost-estimates/ce-2":
+			w.Write([]byte(`{"data":{
+				"id":"ce-2",
+				"type":"cost-estimates",
+				"attributes":{
+					"status":"finished",
+					"proposed-monthly-cost":"10.25",
+					"prior-monthly-cost":"12.25",
+					"delta-monthly-cost":"-2.0",
+					"matched-resources-count":2,
+					"unmatched-resources-count":0
+				}
+			}}`))
+		case "/api/v2/cost-estimates/ce-3":
+			w.Write([]byte(`{"data":{
+				"id":"ce-3",
+				"type":"cost-estimates",
+				"attributes":{"status":"pending"}
+			}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}},
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		defer close(ch)
+		if err = (ScrapeCostEstimates{}).Scrape(context.Background(), config, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+	}()
+
+	counterExpected := []MetricResult{
+		{labels: labelMap{"organization": "test-org", "workspace": "dev"}, value: 25.5, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev"}, value: 5, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "state": "matched"}, value: 4, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "state": "unmatched"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg"}, value: 10.25, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg"}, value: -2, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg", "state": "matched"}, value: 2, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg", "state": "unmatched"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org"}, value: 35.75, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range counterExpected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+)
+
+// organizationsCache holds the organizations discovered through the API when none are configured,
+// so that the Organizations API is only paged through once per refresh interval.
+type organizationsCache struct {
+	mu            sync.Mutex
+	organizations []string
+	refreshedAt   time.Time
+}
+
+// get returns the cached organizations, listing them again once the refresh interval has passed.
+// When listing them again fails, the organizations listed before are returned along with the error.
+// The returned slice is shared by concurrent scrapes, its capacity is capped so that appending to it
+// always allocates a new array instead of writing into the cached one.
+func (c *organizationsCache) get(ctx context.Context, config *setup.Config) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	if c.organizations == nil || time.Since(c.refreshedAt) >= config.OrganizationsRefreshInterval {
+		var organizations []string
+		organizations, err = listOrganizations(ctx, config)
+		switch {
+		case err == nil:
+			c.organizations = organizations
+			c.refreshedAt = time.Now()
+		case c.organizations == nil:
+			return nil, err
+		}
+	}
+
+	return c.organizations[:len(c.organizations):len(c.organizations)], err
+}
+
+// listOrganizations pages through the Organizations API and returns the name of every organization visible to the token.
+func listOrganizations(ctx context.Context, config *setup.Config) ([]string, error) {
+	organizations := []string{}
+	for page := 1; ; page++ {
+		oo, err := config.Client.Organizations.List(ctx, tfe.OrganizationListOptions{
+			ListOptions: tfe.ListOptions{
+				PageSize:   config.PageSize,
+				PageNumber: page,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%v, page=%d", err, page)
+		}
+
+		for _, o := range oo.Items {
+			organizations = append(organizations, o.Name)
+		}
+		if oo.Pagination == nil || page >= oo.Pagination.TotalPages {
+			return organizations, nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestOrganizationsCache(t *testing.T) {
+	requests := 0
+	failing := false
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/organizations" {
+			return
+		}
+		requests++
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		page := r.URL.Query().Get("page[number]")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{
+			"meta":{
+				"pagination":{"current-page":%s,"total-pages":2,"total-count":2}
+			},
+			"data":[{"id":"org-%s","type":"organizations","attributes":{"name":"org-%s"}}]
+		}`, page, page, page)))
+	}))
+	defer mockAPI.Close()
+
+	client, err := tfe.NewClient(&tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	})
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client: *client,
+		CLI:    setup.CLI{OrganizationsRefreshInterval: time.Hour},
+	}
+
+	convey.Convey("Organizations discovery", t, func() {
+		cache := &organizationsCache{}
+
+		organizations, err := cache.get(context.Background(), config)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(organizations, convey.ShouldResemble, []string{"org-1", "org-2"})
+		convey.So(requests, convey.ShouldEqual, 2)
+
+		organizations, err = cache.get(context.Background(), config)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(organizations, convey.ShouldResemble, []string{"org-1", "org-2"})
+		convey.So(requests, convey.ShouldEqual, 2)
+
+		cache.refreshedAt = time.Now().Add(-2 * time.Hour)
+		_, err = cache.get(context.Background(), config)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(requests, convey.ShouldEqual, 4)
+
+		// A failed refresh keeps serving the organizations listed before and is retried by the next scrape.
+		failing = true
+		cache.refreshedAt = time.Now().Add(-2 * time.Hour)
+		organizations, err = cache.get(context.Background(), config)
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(organizations, convey.ShouldResemble, []string{"org-1", "org-2"})
+		_, err = cache.get(context.Background(), config)
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(requests, convey.ShouldBeGreaterThan, 5)
+	})
+}
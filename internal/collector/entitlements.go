@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// organization is the Metric subsystem we use.
+	organizationSubsystem = "organization"
+)
+
+// Metric descriptors.
+var (
+	OrganizationEntitlement = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, organizationSubsystem, "entitlement"),
+		"Whether the organization is entitled to the feature (1) or not (0)",
+		[]string{"organization", "feature"}, nil,
+	)
+	OrganizationRunConcurrencyLimit = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, organizationSubsystem, "run_concurrency_limit"),
+		"Maximum number of runs the subscription of the organization processes at the same time",
+		[]string{"organization"}, nil,
+	)
+	OrganizationAgentLimit = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, organizationSubsystem, "agent_limit"),
+		"Maximum number of agents the subscription of the organization allows",
+		[]string{"organization"}, nil,
+	)
+	OrganizationUserLimit = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, organizationSubsystem, "user_limit"),
+		"Maximum number of users the organization is entitled to",
+		[]string{"organization"}, nil,
+	)
+)
+
+// entitlementSet holds the entitlements of an organization, including the ones not modelled by the go-tfe Entitlements struct.
+type entitlementSet struct {
+	ID                    string `jsonapi:"primary,entitlement-sets"`
+	Agents                bool   `jsonapi:"attr,agents"`
+	AuditLogging          bool   `jsonapi:"attr,audit-logging"`
+	ConfigurationDesigner bool   `jsonapi:"attr,configuration-designer"`
+	CostEstimation        bool   `jsonapi:"attr,cost-estimation"`
+	Operations            bool   `jsonapi:"attr,operations"`
+	PrivateModuleRegistry bool   `jsonapi:"attr,private-module-registry"`
+	SelfServeBilling      bool   `jsonapi:"attr,self-serve-billing"`
+	Sentinel              bool   `jsonapi:"attr,sentinel"`
+	SSO                   bool   `jsonapi:"attr,sso"`
+	StateStorage          bool   `jsonapi:"attr,state-storage"`
+	Teams                 bool   `jsonapi:"attr,teams"`
+	UsageReporting        bool   `jsonapi:"attr,usage-reporting"`
+	VCSIntegrations       bool   `jsonapi:"attr,vcs-integrations"`
+	UserLimit             *int   `jsonapi:"attr,user-limit"`
+}
+
+// feature is one tf_organization_entitlement series of an organization.
+type feature struct {
+	name     string
+	entitled bool
+}
+
+// features returns the entitlements of the set by the name of the feature they enable.
+func (e *entitlementSet) features() []feature {
+	return []feature{
+		{"agents", e.Agents},
+		{"audit_logging", e.AuditLogging},
+		{"configuration_designer", e.ConfigurationDesigner},
+		{"cost_estimation", e.CostEstimation},
+		{"operations", e.Operations},
+		{"private_module_registry", e.PrivateModuleRegistry},
+		{"self_serve_billing", e.SelfServeBilling},
+		{"sentinel", e.Sentinel},
+		{"sso", e.SSO},
+		{"state_storage", e.StateStorage},
+		{"teams", e.Teams},
+		{"usage_reporting", e.UsageReporting},
+		{"vcs_integrations", e.VCSIntegrations},
+	}
+}
+
+// subscription holds the limits of the subscription of an organization, only available on Terraform Cloud.
+type subscription struct {
+	ID            string `jsonapi:"primary,subscriptions"`
+	RunsCeiling   *int   `jsonapi:"attr,runs-ceiling"`
+	AgentsCeiling *int   `jsonapi:"attr,agents-ceiling"`
+}
+
+// ScrapeEntitlements scrapes metrics about the entitlements and limits of the organizations.
+type ScrapeEntitlements struct{}
+
+func init() {
+	Scrapers[ScrapeEntitlements{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeEntitlements) Name() string {
+	return "entitlements"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeEntitlements) Help() string {
+	return "Scrape the entitlement set and subscription of the organizations: https://www.terraform.io/docs/cloud/api/organizations.html#show-the-entitlement-set"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeEntitlements) Version() string {
+	return "v2"
+}
+
+func getEntitlements(ctx context.Context, name string, config *setup.Config, ch chan<- prometheus.Metric) error {
+	e := &entitlementSet{}
+	if err := readAPI(ctx, config, "organizations/"+url.PathEscape(name)+"/entitlement-set", nil, e); err != nil {
+		return fmt.Errorf("%v, organization=%s", err, name)
+	}
+
+	metrics := []prometheus.Metric{}
+	for _, f := range e.features() {
+		metrics = append(metrics, prometheus.MustNewConstMetric(OrganizationEntitlement, prometheus.GaugeValue, boolToFloat(f.entitled), name, f.name))
+	}
+	if e.UserLimit != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(OrganizationUserLimit, prometheus.GaugeValue, float64(*e.UserLimit), name))
+	}
+
+	s := &subscription{}
+	err := readAPI(ctx, config, "organizations/"+url.PathEscape(name)+"/subscription", nil, s)
+	switch {
+	case err == tfe.ErrResourceNotFound:
+		// Terraform Enterprise has no subscriptions.
+	case err != nil:
+		return fmt.Errorf("%v, organization=%s", err, name)
+	default:
+		if s.RunsCeiling != nil {
+			metrics = append(metrics, prometheus.MustNewConstMetric(OrganizationRunConcurrencyLimit, prometheus.GaugeValue, float64(*s.RunsCeiling), name))
+		}
+		if s.AgentsCeiling != nil {
+			metrics = append(metrics, prometheus.MustNewConstMetric(OrganizationAgentLimit, prometheus.GaugeValue, float64(*s.AgentsCeiling), name))
+		}
+	}
+
+	for _, m := range metrics {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+func (ScrapeEntitlements) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, name := range config.Organizations {
+		name := name
+		g.Go(func() error {
+			return getEntitlements(ctx, name, config, ch)
+		})
+	}
+
+	return g.Wait()
+}
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewProbeHandler returns a handler that scrapes the target named in the request,
+// optionally limited to the requested organizations.
+func NewProbeHandler(config setup.Config) http.HandlerFunc {
+	var mu sync.Mutex
+	targetMetrics := map[string]Metrics{}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("target")
+		if name == "" {
+			http.Error(w, "'target' parameter must be specified", http.StatusBadRequest)
+			return
+		}
+		if _, ok := config.Targets[name]; !ok {
+			http.Error(w, fmt.Sprintf("Unknown target %q", name), http.StatusBadRequest)
+			return
+		}
+
+		targetConfig, err := config.Target(name)
+		if err != nil {
+			level.Error(config.Logger).Log("msg", "Error creating tfe client for target", "target", name, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if organizations := r.URL.Query()["organization"]; len(organizations) > 0 {
+			targetConfig.Organizations = organizations
+		}
+
+		// Keep exporter metrics, such as the scrape error counters, separate per target.
+		mu.Lock()
+		metrics, ok := targetMetrics[name]
+		if !ok {
+			metrics = NewMetrics()
+			targetMetrics[name] = metrics
+		}
+		mu.Unlock()
+
+		NewHandler(metrics, targetConfig)(w, r)
+	}
+}
+
+// NewHandler returns a handler that scrapes the API described by config on every request
+// and serves the result along with the given gatherers.
+func NewHandler(metrics Metrics, config setup.Config, gatherers ...prometheus.Gatherer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Use request context for cancellation when connection gets closed.
+		ctx := r.Context()
+		// If a timeout is configured via the Prometheus header, add it to the context.
+		if v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); v != "" {
+			timeoutSeconds, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				level.Error(config.Logger).Log("msg", "Failed to parse timeout from Prometheus header", "err", err)
+			} else {
+				// Create new timeout context with request context as parent.
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutSeconds*float64(time.Second)))
+				defer cancel()
+				// Overwrite request with timeout context.
+				r = r.WithContext(ctx)
+			}
+		}
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(New(ctx, config, metrics))
+
+		// Copy the gatherers, the slice is shared by all requests.
+		requestGatherers := append(append(prometheus.Gatherers{}, gatherers...), registry)
+		// Delegate http serving to Prometheus client library, which will call Exporter.Collect.
+		h := promhttp.HandlerFor(requestGatherers, promhttp.HandlerOpts{})
+		h.ServeHTTP(w, r)
+	}
+}
+
+// NewCachedHandler returns a handler that serves the metrics held by cache along with the given gatherers.
+func NewCachedHandler(cache *Cache, gatherers ...prometheus.Gatherer) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(cache)
+
+	gatherers = append(gatherers, registry)
+	return promhttp.HandlerFor(prometheus.Gatherers(gatherers), promhttp.HandlerOpts{})
+}
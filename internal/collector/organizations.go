@@ -20,17 +20,29 @@ const (
 // Metric descriptors.
 var (
 	OrganizationsInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, organizationsSubsystem, "info"),
+		"Information about existing organizations",
+		[]string{"name", "email", "external_id", "owners_team_saml_role_id", "saml_enabled", "two_factor_conformant"}, nil,
+	)
+	// OrganizationsInfoLegacy is sent instead of OrganizationsInfo with --compat.timestamp-labels.
+	// Deprecated: use OrganizationsCreated instead of the created_at label.
+	OrganizationsInfoLegacy = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, organizationsSubsystem, "info"),
 		"Information about existing organizations",
 		[]string{"name", "created_at", "email", "external_id", "owners_team_saml_role_id", "saml_enabled", "two_factor_conformant"}, nil,
 	)
+	OrganizationsCreated = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, organizationsSubsystem, "created_timestamp_seconds"),
+		"Unix timestamp at which the organization was created",
+		[]string{"name"}, nil,
+	)
 )
 
 // ScrapeOrganizations scrapes metrics about the organizations.
 type ScrapeOrganizations struct{}
 
 func init() {
-	Scrapers = append(Scrapers, ScrapeOrganizations{})
+	Scrapers[ScrapeOrganizations{}] = true
 }
 
 // Name of the Scraper. Should be unique.
@@ -54,21 +66,41 @@ func getOrganization(ctx context.Context, name string, config *setup.Config, ch
 		return fmt.Errorf("%v, organization=%s", err, name)
 	}
 
-	select {
-	case ch <- prometheus.MustNewConstMetric(
+	info := prometheus.MustNewConstMetric(
 		OrganizationsInfo,
 		prometheus.GaugeValue,
 		1,
 		o.Name,
-		o.CreatedAt.String(),
 		o.Email,
 		o.ExternalID,
 		o.OwnersTeamSAMLRoleID,
 		strconv.FormatBool(o.SAMLEnabled),
 		strconv.FormatBool(o.TwoFactorConformant),
-	):
-	case <-ctx.Done():
-		return ctx.Err()
+	)
+	if config.CompatTimestampLabels {
+		info = prometheus.MustNewConstMetric(
+			OrganizationsInfoLegacy,
+			prometheus.GaugeValue,
+			1,
+			o.Name,
+			o.CreatedAt.String(),
+			o.Email,
+			o.ExternalID,
+			o.OwnersTeamSAMLRoleID,
+			strconv.FormatBool(o.SAMLEnabled),
+			strconv.FormatBool(o.TwoFactorConformant),
+		)
+	}
+
+	for _, m := range []prometheus.Metric{
+		info,
+		prometheus.MustNewConstMetric(OrganizationsCreated, prometheus.GaugeValue, timestampSeconds(o.CreatedAt), o.Name),
+	} {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 
 	return nil
@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// plan is the Metric subsystem we use.
+	planSubsystem = "plan"
+)
+
+// Metric descriptors.
+var (
+	PlanResourceAdditions = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, planSubsystem, "resource_additions"),
+		"Number of resources the plan of the current run of the workspace proposes to add",
+		[]string{"organization", "workspace"}, nil,
+	)
+	PlanResourceChanges = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, planSubsystem, "resource_changes"),
+		"Number of resources the plan of the current run of the workspace proposes to change",
+		[]string{"organization", "workspace"}, nil,
+	)
+	PlanResourceDestructions = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, planSubsystem, "resource_destructions"),
+		"Number of resources the plan of the current run of the workspace proposes to destroy",
+		[]string{"organization", "workspace"}, nil,
+	)
+	PlanHasChanges = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, planSubsystem, "has_changes"),
+		"Whether the plan of the current run of the workspace has changes (1) or not (0)",
+		[]string{"organization", "workspace"}, nil,
+	)
+)
+
+// ScrapePlans scrapes metrics about the plan of the current run of every workspace.
+type ScrapePlans struct{}
+
+func init() {
+	Scrapers[ScrapePlans{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapePlans) Name() string {
+	return "plans"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapePlans) Help() string {
+	return "Scrape the plans of the current runs from the Plans API: https://www.terraform.io/docs/cloud/api/plans.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapePlans) Version() string {
+	return "v2"
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+// The plans are embedded in the workspaces list, so no request is made per workspace.
+func (ScrapePlans) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	return scrapeWorkspacesPages(ctx, config, "current_run.plan", func(ctx context.Context, workspaces []*workspace) error {
+		for _, w := range workspaces {
+			if w.CurrentRun == nil || w.CurrentRun.Plan == nil {
+				continue
+			}
+
+			p := w.CurrentRun.Plan
+			for _, m := range []prometheus.Metric{
+				prometheus.MustNewConstMetric(PlanResourceAdditions, prometheus.GaugeValue, float64(p.ResourceAdditions), w.Organization.Name, w.Name),
+				prometheus.MustNewConstMetric(PlanResourceChanges, prometheus.GaugeValue, float64(p.ResourceChanges), w.Organization.Name, w.Name),
+				prometheus.MustNewConstMetric(PlanResourceDestructions, prometheus.GaugeValue, float64(p.ResourceDestructions), w.Organization.Name, w.Name),
+				prometheus.MustNewConstMetric(PlanHasChanges, prometheus.GaugeValue, boolToFloat(p.HasChanges), w.Organization.Name, w.Name),
+			} {
+				select {
+				case ch <- m:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		return nil
+	})
+}
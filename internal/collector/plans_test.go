@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrapePlans(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("include") != "current_run.plan" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{
+			"meta":{
+				"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":2}
+			},
+			"data":[{
+				"id":"test-id-1",
+				"type":"workspaces",
+				"attributes":{"name":"dev"},
+				"relationships":{
+					"organization":{"data":{"id":"test-org","type":"organizations"}},
+					"current-run":{"data":{"id":"run-id-1","type":"runs"}}
+				}
+			}, {
+				"id":"test-id-2",
+				"type":"workspaces",
+				"attributes":{"name":"stg"},
+				"relationships":{
+					"organization":{"data":{"id":"test-org","type":"organizations"}}
+				}
+			}],
+			"included":[{
+				"id":"run-id-1",
+				"type":"runs",
+				"attributes":{"status":"planned"},
+				"relationships":{
+					"plan":{"data":{"id":"plan-id-1","type":"plans"}}
+				}
+			}, {
+				"id":"plan-id-1",
+				"type":"plans",
+				"attributes":{
+					"has-changes":true,
+					"resource-additions":3,
+					"resource-changes":2,
+					"resource-destructions":1
+				}
+			}]
+		}`))
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}},
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		defer close(ch)
+		if err = (ScrapePlans{}).Scrape(context.Background(), config, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+	}()
+
+	counterExpected := []MetricResult{
+		{labels: labelMap{"organization": "test-org", "workspace": "dev"}, value: 3, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev"}, value: 2, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev"}, value: 1, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range counterExpected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
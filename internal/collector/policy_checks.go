@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// policy_checks is the Metric subsystem we use.
+	policyChecksSubsystem = "policy_checks"
+)
+
+// Metric descriptors.
+var (
+	PolicyChecks = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", policyChecksSubsystem),
+		"Number of policy sets evaluated by the policy checks of the current run of the workspace, by result",
+		[]string{"organization", "workspace", "policy_set", "result"}, nil,
+	)
+	PolicyChecksFailedPolicies = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, policyChecksSubsystem, "failed_policies"),
+		"Number of policies that failed in the policy checks of the current run of the workspace, by enforcement level",
+		[]string{"organization", "workspace", "enforcement_level"}, nil,
+	)
+)
+
+// policyCheck holds the attributes of a policy check we need, the go-tfe PolicyCheck struct does not model the
+// result of each policy set.
+type policyCheck struct {
+	ID     string             `jsonapi:"primary,policy-checks"`
+	Status string             `jsonapi:"attr,status"`
+	Result *policyCheckResult `jsonapi:"attr,result"`
+}
+
+type policyCheckResult struct {
+	AdvisoryFailed int `json:"advisory-failed"`
+	SoftFailed     int `json:"soft-failed"`
+	HardFailed     int `json:"hard-failed"`
+	Sentinel       *struct {
+		// Data maps the name of every policy set evaluated to its result.
+		Data map[string]policySetResult `json:"data"`
+	} `json:"sentinel"`
+}
+
+type policySetResult struct {
+	CanOverride bool        `json:"can-override"`
+	Error       interface{} `json:"error"`
+	Result      bool        `json:"result"`
+}
+
+// ScrapePolicyChecks scrapes metrics about the policy checks of the current run of every workspace.
+type ScrapePolicyChecks struct{}
+
+func init() {
+	Scrapers[ScrapePolicyChecks{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapePolicyChecks) Name() string {
+	return policyChecksSubsystem
+}
+
+// Help describes the role of the Scraper.
+func (ScrapePolicyChecks) Help() string {
+	return "Scrape the policy checks of the current runs from the Policy Checks API: https://www.terraform.io/docs/cloud/api/policy-checks.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapePolicyChecks) Version() string {
+	return "v2"
+}
+
+// listPolicyChecks pages through the Policy Checks API and returns every policy check of a run.
+func listPolicyChecks(ctx context.Context, runID string, config *setup.Config) ([]*policyCheck, error) {
+	checks := []*policyCheck{}
+	for page := 1; ; page++ {
+		items, pagination, err := listAPI(ctx, config, fmt.Sprintf("runs/%s/policy-checks", runID), pageQuery(page, config), reflect.TypeOf(&policyCheck{}))
+		if err != nil {
+			return nil, fmt.Errorf("%v, (run=%s, page=%d)", err, runID, page)
+		}
+
+		for _, item := range items {
+			checks = append(checks, item.(*policyCheck))
+		}
+		if page >= pagination.TotalPages {
+			return checks, nil
+		}
+	}
+}
+
+// policySetOutcome returns the result of a policy set evaluated by a policy check: passed, soft_failed,
+// hard_failed, overridden or errored.
+func policySetOutcome(check *policyCheck, set policySetResult) string {
+	switch {
+	case set.Error != nil:
+		return "errored"
+	case set.Result:
+		return "passed"
+	case !set.CanOverride:
+		return "hard_failed"
+	case check.Status == "overridden":
+		return "overridden"
+	default:
+		return "soft_failed"
+	}
+}
+
+// policySetCount identifies one tf_policy_checks series of a workspace.
+type policySetCount struct {
+	policySet, result string
+}
+
+func getRunPolicyChecks(ctx context.Context, organization, workspace, runID string, config *setup.Config, ch chan<- prometheus.Metric) error {
+	checks, err := listPolicyChecks(ctx, runID, config)
+	if err != nil {
+		return err
+	}
+
+	counts := map[policySetCount]int{}
+	failed := map[string]int{}
+	for _, check := range checks {
+		if check.Result == nil {
+			continue
+		}
+
+		failed["advisory"] += check.Result.AdvisoryFailed
+		failed["soft-mandatory"] += check.Result.SoftFailed
+		failed["hard-mandatory"] += check.Result.HardFailed
+		if check.Result.Sentinel == nil || len(check.Result.Sentinel.Data) == 0 {
+			// Without the details of each policy set, the status of the check is the best we have.
+			switch check.Status {
+			case "passed", "soft_failed", "hard_failed", "overridden", "errored":
+				counts[policySetCount{result: check.Status}]++
+			}
+			continue
+		}
+		for name, set := range check.Result.Sentinel.Data {
+			counts[policySetCount{policySet: name, result: policySetOutcome(check, set)}]++
+		}
+	}
+
+	keys := make([]policySetCount, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].policySet != keys[j].policySet {
+			return keys[i].policySet < keys[j].policySet
+		}
+		return keys[i].result < keys[j].result
+	})
+
+	metrics := []prometheus.Metric{}
+	for _, key := range keys {
+		metrics = append(metrics, prometheus.MustNewConstMetric(PolicyChecks, prometheus.GaugeValue, float64(counts[key]), organization, workspace, key.policySet, key.result))
+	}
+	if len(checks) > 0 {
+		for _, level := range []string{"advisory", "soft-mandatory", "hard-mandatory"} {
+			metrics = append(metrics, prometheus.MustNewConstMetric(PolicyChecksFailedPolicies, prometheus.GaugeValue, float64(failed[level]), organization, workspace, level))
+		}
+	}
+
+	for _, m := range metrics {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+// Only the current runs referencing policy checks cost an extra request.
+func (ScrapePolicyChecks) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	return scrapeWorkspacesPages(ctx, config, "current_run", func(ctx context.Context, workspaces []*workspace) error {
+		for _, w := range workspaces {
+			if w.CurrentRun == nil || len(w.CurrentRun.PolicyChecks) == 0 {
+				continue
+			}
+			if err := getRunPolicyChecks(ctx, w.Organization.Name, w.Name, w.CurrentRun.ID, config, ch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrapePolicyChecks(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/organizations/test-org/workspaces":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":3}
+				},
+				"data":[{
+					"id":"test-id-1",
+					"type":"workspaces",
+					"attributes":{"name":"dev"},
+					"relationships":{
+						"organization":{"data":{"id":"test-org","type":"organizations"}},
+						"current-run":{"data":{"id":"run-id-1","type":"runs"}}
+					}
+				}, {
+					"id":"test-id-2",
+					"type":"workspaces",
+					"attributes":{"name":"stg"},
+					"relationships":{
+						"organization":{"data":{"id":"test-org","type":"organizations"}},
+						"current-run":{"data":{"id":"run-id-2","type":"runs"}}
+					}
+				}, {
+					"id":"test-id-3",
+					"type":"workspaces",
+					"attributes":{"name":"prd"},
+					"relationships":{
+						"organization":{"data":{"id":"test-org","type":"organizations"}},
+						"current-run":{"data":{"id":"run-id-3","type":"runs"}}
+					}
+				}],
+				"included":[{
+					"id":"run-id-1",
+					"type":"runs",
+					"attributes":{"status":"policy_checked"},
+					"relationships":{"policy-checks":{"data":[{"id":"polchk-1","type":"policy-checks"}]}}
+				}, {
+					"id":"run-id-2",
+					"type":"runs",
+					"attributes":{"status":"applied"},
+					"relationships":{"policy-checks":{"data":[{"id":"polchk-2","type":"policy-checks"}]}}
+				}, {
+					"id":"run-id-3",
+					"type":"runs",
+					"attributes":{"status":"applied"},
+					"relationships":{"policy-checks":{"data":[]}}
+				}]
+			}`))
+		case "/api/v2/runs/run-id-1/policy-checks":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":1}
+				},
+				"data":[{
+					"id":"polchk-1",
+					"type":"policy-checks",
+					"attributes":{
+						"status":"hard_failed",
+						"result":{
+							"advisory-failed":1,
+							"soft-failed":1,
+							"hard-failed":1,
+							"sentinel":{
+								"schema-version":"1.0.0",
+								"data":{
+									"set-passed":{"can-override":false,"error":null,"result":true},
+									"set-soft":{"can-override":true,"error":null,"result":false},
+									"set-hard":{"can-override":false,"error":null,"result":false},
+									"set-errored":{"can-override":false,"error":{"message":"boom"},"result":false}
+								}
+							}
+						}
+					}
+				}]
+			}`))
+		case "/api/v2/runs/run-id-2/policy-checks":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":1}
+				},
+				"data":[{
+					"id":"polchk-2",
+					"type":"policy-checks",
+					"attributes":{
+						"status":"overridden",
+						"result":{
+							"advisory-failed":0,
+							"soft-failed":2,
+							"hard-failed":0,
+							"sentinel":{
+								"schema-version":"1.0.0",
+								"data":{
+									"set-soft":{"can-override":true,"error":null,"result":false}
+								}
+							}
+						}
+					}
+				}]
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}},
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		defer close(ch)
+		if err = (ScrapePolicyChecks{}).Scrape(context.Background(), config, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+	}()
+
+	counterExpected := []MetricResult{
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "policy_set": "set-errored", "result": "errored"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "policy_set": "set-hard", "result": "hard_failed"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "policy_set": "set-passed", "result": "passed"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "policy_set": "set-soft", "result": "soft_failed"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "enforcement_level": "advisory"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "enforcement_level": "soft-mandatory"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "enforcement_level": "hard-mandatory"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg", "policy_set": "set-soft", "result": "overridden"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg", "enforcement_level": "advisory"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg", "enforcement_level": "soft-mandatory"}, value: 2, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg", "enforcement_level": "hard-mandatory"}, value: 0, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range counterExpected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric descriptors.
+var (
+	RunsQueued = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, runsSubsystem, "queued"),
+		"Number of runs of the workspace waiting in a queue, by status",
+		[]string{"organization", "workspace", "status"}, nil,
+	)
+	RunQueueWait = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "run", "queue_wait_seconds"),
+		"Time runs spent between being created (pending) and starting to plan, computed from their status timestamps, each run is observed once",
+		[]string{"organization"}, nil,
+	)
+
+	// queuedRunStatuses are the statuses of runs waiting for the organization concurrency or an agent.
+	queuedRunStatuses = []string{"pending", "plan_queued", "apply_queued"}
+
+	runQueueWaitBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+)
+
+// runQueueWaits accumulates the queue wait of the runs listed by the run_queue collector across the scrapes of a
+// target, observing each run once so that tf_run_queue_wait_seconds only ever goes up.
+type runQueueWaits struct {
+	mu         sync.Mutex
+	observed   runObservations
+	histograms map[string]*runHistogram
+}
+
+func newRunQueueWaits() *runQueueWaits {
+	return &runQueueWaits{
+		observed:   newRunObservations(),
+		histograms: map[string]*runHistogram{},
+	}
+}
+
+// record observes the queue wait of the runs of an organization that started to plan since the previous scrapes.
+func (q *runQueueWaits) record(organization string, runs []*run, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, r := range runs {
+		if r.StatusTimestamps == nil || r.StatusTimestamps.PlanningAt.IsZero() || r.CreatedAt.IsZero() {
+			continue
+		}
+		if !q.observed.first(r.ID, now) {
+			continue
+		}
+		h, ok := q.histograms[organization]
+		if !ok {
+			h = newRunHistogram(runQueueWaitBuckets)
+			q.histograms[organization] = h
+		}
+		h.observe(r.StatusTimestamps.PlanningAt.Sub(r.CreatedAt))
+	}
+}
+
+// metrics returns the accumulated histograms of the organizations sorted by name, forgetting the runs not listed
+// for runObservationTTL.
+func (q *runQueueWaits) metrics(now time.Time, organizations []string) []prometheus.Metric {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.observed.evict(now)
+
+	organizations = append([]string{}, organizations...)
+	sort.Strings(organizations)
+
+	metrics := make([]prometheus.Metric, 0, len(organizations))
+	for _, organization := range organizations {
+		h, ok := q.histograms[organization]
+		if !ok {
+			continue
+		}
+		metrics = append(metrics, prometheus.MustNewConstHistogram(RunQueueWait, h.count, h.sum, h.snapshot(), organization))
+	}
+	return metrics
+}
+
+// ScrapeRunQueue scrapes metrics about the runs waiting to be processed.
+type ScrapeRunQueue struct{}
+
+func init() {
+	Scrapers[ScrapeRunQueue{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeRunQueue) Name() string {
+	return "run_queue"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeRunQueue) Help() string {
+	return "Scrape the queued runs from the most recent page of the Runs API: https://www.terraform.io/docs/cloud/api/run.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeRunQueue) Version() string {
+	return "v2"
+}
+
+// listRecentRuns returns the first page of runs of a workspace, the runs are listed newest first so it holds every queued run
+// unless more than config.PageSize runs are queued.
+func listRecentRuns(ctx context.Context, workspaceID string, config *setup.Config) ([]*run, error) {
+	items, _, err := listAPI(ctx, config, fmt.Sprintf("workspaces/%s/runs", workspaceID), pageQuery(1, config), reflect.TypeOf(&run{}))
+	if err != nil {
+		return nil, fmt.Errorf("%v, workspace=%s", err, workspaceID)
+	}
+
+	runs := make([]*run, 0, len(items))
+	for _, item := range items {
+		runs = append(runs, item.(*run))
+	}
+	return runs, nil
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+func (ScrapeRunQueue) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	waits := metricsFrom(ctx).runQueue
+	now := time.Now()
+	err := scrapeWorkspacesPages(ctx, config, "", func(ctx context.Context, workspaces []*workspace) error {
+		for _, w := range workspaces {
+			runs, err := listRecentRuns(ctx, w.ID, config)
+			if err != nil {
+				return err
+			}
+			waits.record(w.Organization.Name, runs, now)
+
+			queued := map[string]int{}
+			for _, r := range runs {
+				queued[r.Status]++
+			}
+
+			for _, status := range queuedRunStatuses {
+				select {
+				case ch <- prometheus.MustNewConstMetric(RunsQueued, prometheus.GaugeValue, float64(queued[status]), w.Organization.Name, w.Name, status):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The histograms are sent once every page of workspaces is done.
+	for _, m := range waits.metrics(now, config.Organizations) {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
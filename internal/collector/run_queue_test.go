@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrapeRunQueue(t *testing.T) {
+	// run-id-2 starts to plan between the two scrapes.
+	run2 := `"attributes":{"status":"pending","created-at":"2020-10-10T10:15:00Z"}`
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/organizations/test-org/workspaces":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":1}
+				},
+				"data":[{
+					"id":"test-id-1",
+					"type":"workspaces",
+					"attributes":{"name":"dev"},
+					"relationships":{"organization":{"data":{"id":"test-org","type":"organizations"}}}
+				}]
+			}`))
+		case "/api/v2/workspaces/test-id-1/runs":
+			if r.URL.Query().Get("page[number]") != "1" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":2,"total-pages":2,"total-count":25}
+				},
+				"data":[{
+					"id":"run-id-1",
+					"type":"runs",
+					"attributes":{"status":"pending","created-at":"2020-10-10T10:20:00Z"}
+				}, {
+					"id":"run-id-2",
+					"type":"runs",
+					` + run2 + `
+				}, {
+					"id":"run-id-3",
+					"type":"runs",
+					"attributes":{
+						"status":"planning",
+						"created-at":"2020-10-10T10:00:00Z",
+						"status-timestamps":{"planning-at":"2020-10-10T10:02:00Z"}
+					}
+				}, {
+					"id":"run-id-4",
+					"type":"runs",
+					"attributes":{
+						"status":"applied",
+						"created-at":"2020-10-09T10:00:00Z",
+						"status-timestamps":{"planning-at":"2020-10-09T10:00:20Z","applied-at":"2020-10-09T10:05:00Z"}
+					}
+				}]
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}},
+	}
+
+	ctx := withMetrics(context.Background(), NewMetrics())
+
+	// The runs listed again by the second scrape are not observed twice.
+	for scrape := 1; scrape <= 2; scrape++ {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			defer close(ch)
+			if err := (ScrapeRunQueue{}).Scrape(ctx, config, ch); err != nil {
+				t.Errorf("error calling function on test: %s", err)
+			}
+		}()
+
+		counterExpected := []MetricResult{
+			{labels: labelMap{"organization": "test-org", "workspace": "dev", "status": "pending"}, value: 2, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"organization": "test-org", "workspace": "dev", "status": "plan_queued"}, value: 0, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"organization": "test-org", "workspace": "dev", "status": "apply_queued"}, value: 0, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"organization": "test-org"}, value: 140, metricType: dto.MetricType_HISTOGRAM},
+		}
+		if scrape == 2 {
+			counterExpected[0].value = 1
+			counterExpected[3].value = 200
+		}
+		convey.Convey(fmt.Sprintf("Metrics comparison, scrape %d", scrape), t, func() {
+			for _, expect := range counterExpected {
+				got := readMetric(<-ch)
+				convey.So(got, convey.ShouldResemble, expect)
+			}
+			_, ok := <-ch
+			convey.So(ok, convey.ShouldBeFalse)
+		})
+
+		run2 = `"attributes":{
+						"status":"planning",
+						"created-at":"2020-10-10T10:15:00Z",
+						"status-timestamps":{"planning-at":"2020-10-10T10:16:00Z"}
+					}`
+	}
+}
+
+func TestRunQueueWaits(t *testing.T) {
+	created := time.Date(2020, 10, 10, 10, 0, 0, 0, time.UTC)
+	started := func(id string) []*run {
+		return []*run{{ID: id, CreatedAt: created, StatusTimestamps: &runStatusTimestamps{PlanningAt: created.Add(time.Minute)}}}
+	}
+
+	convey.Convey("Only export the scraped organizations", t, func() {
+		waits := newRunQueueWaits()
+		waits.record("org-a", started("run-id-1"), created)
+		waits.record("org-b", started("run-id-2"), created)
+
+		metrics := waits.metrics(created, []string{"org-b"})
+		convey.So(metrics, convey.ShouldHaveLength, 1)
+		convey.So(readMetric(metrics[0]).labels["organization"], convey.ShouldEqual, "org-b")
+	})
+}
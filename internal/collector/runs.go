@@ -0,0 +1,361 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// runs is the Metric subsystem we use.
+	runsSubsystem = "runs"
+)
+
+// Metric descriptors.
+var (
+	RunsTotal = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, runsSubsystem, "total"),
+		"Number of finished runs per workspace by final status, source and trigger reason, counted since the exporter started",
+		[]string{"organization", "workspace", "status", "source", "trigger_reason"}, nil,
+	)
+	RunDuration = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "run", "duration_seconds"),
+		"Duration of the plan and apply phases of the runs finished since the exporter started, computed from their status timestamps",
+		[]string{"organization", "workspace", "phase"}, nil,
+	)
+
+	runDurationBuckets = []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200}
+
+	// finalRunStatuses are the statuses runs do not leave once in, runs are counted when they reach one of them.
+	finalRunStatuses = map[string]bool{"applied": true, "planned_and_finished": true, "errored": true, "discarded": true, "canceled": true, "force_canceled": true}
+)
+
+// runObservationTTL is how long an observed run that is not listed anymore is remembered.
+const runObservationTTL = 24 * time.Hour
+
+// run holds the attributes of a run we need that are not modelled by the go-tfe Run struct.
+type run struct {
+	ID               string               `jsonapi:"primary,runs"`
+	CreatedAt        time.Time            `jsonapi:"attr,created-at,iso8601"`
+	Source           string               `jsonapi:"attr,source"`
+	Status           string               `jsonapi:"attr,status"`
+	TriggerReason    string               `jsonapi:"attr,trigger-reason"`
+	StatusTimestamps *runStatusTimestamps `jsonapi:"attr,status-timestamps"`
+}
+
+// runStatusTimestamps holds the time at which a run entered each status.
+type runStatusTimestamps struct {
+	PlanQueuedAt         time.Time `json:"plan-queued-at"`
+	PlanningAt           time.Time `json:"planning-at"`
+	PlannedAt            time.Time `json:"planned-at"`
+	PlannedAndFinishedAt time.Time `json:"planned-and-finished-at"`
+	ApplyQueuedAt        time.Time `json:"apply-queued-at"`
+	ApplyingAt           time.Time `json:"applying-at"`
+	AppliedAt            time.Time `json:"applied-at"`
+	ErroredAt            time.Time `json:"errored-at"`
+}
+
+// ScrapeRuns scrapes metrics about the runs of every workspace.
+type ScrapeRuns struct{}
+
+func init() {
+	Scrapers[ScrapeRuns{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeRuns) Name() string {
+	return runsSubsystem
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeRuns) Help() string {
+	return "Scrape information from the Runs API: https://www.terraform.io/docs/cloud/api/run.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeRuns) Version() string {
+	return "v2"
+}
+
+// listRuns returns the runs of a workspace from the first maxPages pages of the Runs API, newest first.
+func listRuns(ctx context.Context, workspaceID string, maxPages int, config *setup.Config) ([]*run, error) {
+	runs := []*run{}
+	for page := 1; page <= maxPages; page++ {
+		items, pagination, err := listAPI(ctx, config, fmt.Sprintf("workspaces/%s/runs", workspaceID), pageQuery(page, config), reflect.TypeOf(&run{}))
+		if err != nil {
+			return nil, fmt.Errorf("%v, (workspace=%s, page=%d)", err, workspaceID, page)
+		}
+
+		for _, item := range items {
+			runs = append(runs, item.(*run))
+		}
+		if page >= pagination.TotalPages {
+			break
+		}
+	}
+	return runs, nil
+}
+
+// runDurations returns the duration of each finished phase of a run.
+func runDurations(r *run) map[string]time.Duration {
+	durations := map[string]time.Duration{}
+	ts := r.StatusTimestamps
+	if ts == nil {
+		return durations
+	}
+
+	if !ts.PlanningAt.IsZero() {
+		if !ts.PlannedAt.IsZero() {
+			durations["plan"] = ts.PlannedAt.Sub(ts.PlanningAt)
+		} else if !ts.PlannedAndFinishedAt.IsZero() {
+			durations["plan"] = ts.PlannedAndFinishedAt.Sub(ts.PlanningAt)
+		}
+	}
+	if !ts.ApplyingAt.IsZero() && !ts.AppliedAt.IsZero() {
+		durations["apply"] = ts.AppliedAt.Sub(ts.ApplyingAt)
+	}
+
+	return durations
+}
+
+// runObservations remembers what was already recorded about each run, e.g. "run-1/plan", as the most recent runs of
+// a workspace are listed again by every scrape. Runs that are not listed anymore are older than the ones listed and
+// are never listed again, they are forgotten after runObservationTTL.
+type runObservations struct {
+	listedAt map[string]time.Time
+}
+
+func newRunObservations() runObservations {
+	return runObservations{listedAt: map[string]time.Time{}}
+}
+
+// first reports whether key is observed for the first time.
+func (o runObservations) first(key string, now time.Time) bool {
+	_, ok := o.listedAt[key]
+	o.listedAt[key] = now
+	return !ok
+}
+
+// evict forgets the observations not listed since runObservationTTL before now.
+func (o runObservations) evict(now time.Time) {
+	for key, listedAt := range o.listedAt {
+		if now.Sub(listedAt) > runObservationTTL {
+			delete(o.listedAt, key)
+		}
+	}
+}
+
+// runWorkspace identifies the workspace the series of the runs collector belong to.
+type runWorkspace struct {
+	organization, workspace string
+}
+
+// runCount identifies one tf_runs_total series.
+type runCount struct {
+	organization, workspace, status, source, triggerReason string
+}
+
+// runPhase identifies one tf_run_duration_seconds series.
+type runPhase struct {
+	organization, workspace, phase string
+}
+
+// runHistogram accumulates the observations of one histogram series computed from runs.
+type runHistogram struct {
+	count   uint64
+	sum     float64
+	bounds  []float64
+	buckets map[float64]uint64
+}
+
+func newRunHistogram(bounds []float64) *runHistogram {
+	h := &runHistogram{bounds: bounds, buckets: map[float64]uint64{}}
+	for _, b := range bounds {
+		h.buckets[b] = 0
+	}
+	return h
+}
+
+func (h *runHistogram) observe(d time.Duration) {
+	h.count++
+	h.sum += d.Seconds()
+	for _, b := range h.bounds {
+		if d.Seconds() <= b {
+			h.buckets[b]++
+		}
+	}
+}
+
+// snapshot returns a copy of the buckets, to build a metric that is written after h is observed again.
+func (h *runHistogram) snapshot() map[float64]uint64 {
+	buckets := make(map[float64]uint64, len(h.buckets))
+	for b, n := range h.buckets {
+		buckets[b] = n
+	}
+	return buckets
+}
+
+// runTotals accumulates the runs counted by the runs collector across the scrapes of a target, so that
+// tf_runs_total and tf_run_duration_seconds only ever go up.
+type runTotals struct {
+	mu         sync.Mutex
+	observed   runObservations
+	recordedAt map[runWorkspace]time.Time
+	counts     map[runCount]float64
+	histograms map[runPhase]*runHistogram
+}
+
+func newRunTotals() *runTotals {
+	return &runTotals{
+		observed:   newRunObservations(),
+		recordedAt: map[runWorkspace]time.Time{},
+		counts:     map[runCount]float64{},
+		histograms: map[runPhase]*runHistogram{},
+	}
+}
+
+// record counts the runs of a workspace that finished and the phases that completed since the previous scrapes.
+func (t *runTotals) record(organization, workspace string, runs []*run, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.recordedAt[runWorkspace{organization: organization, workspace: workspace}] = now
+	for _, r := range runs {
+		if finalRunStatuses[r.Status] && t.observed.first(r.ID+"/status", now) {
+			t.counts[runCount{organization: organization, workspace: workspace, status: r.Status, source: r.Source, triggerReason: r.TriggerReason}]++
+		}
+
+		for phase, d := range runDurations(r) {
+			if !t.observed.first(r.ID+"/"+phase, now) {
+				continue
+			}
+			key := runPhase{organization: organization, workspace: workspace, phase: phase}
+			h, ok := t.histograms[key]
+			if !ok {
+				h = newRunHistogram(runDurationBuckets)
+				t.histograms[key] = h
+			}
+			h.observe(d)
+		}
+	}
+}
+
+// metrics returns the accumulated series of the organizations sorted by labels. The series of the workspaces of
+// these organizations that were not recorded by the scrape at now, e.g. deleted ones, and the runs not listed for
+// runObservationTTL are forgotten.
+func (t *runTotals) metrics(now time.Time, organizations []string) []prometheus.Metric {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.observed.evict(now)
+
+	scraped := make(map[string]bool, len(organizations))
+	for _, organization := range organizations {
+		scraped[organization] = true
+	}
+	for key, recordedAt := range t.recordedAt {
+		if scraped[key.organization] && !recordedAt.Equal(now) {
+			delete(t.recordedAt, key)
+		}
+	}
+
+	counts := make([]runCount, 0, len(t.counts))
+	for key := range t.counts {
+		if !scraped[key.organization] {
+			continue
+		}
+		if _, ok := t.recordedAt[runWorkspace{organization: key.organization, workspace: key.workspace}]; !ok {
+			delete(t.counts, key)
+			continue
+		}
+		counts = append(counts, key)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.organization != b.organization {
+			return a.organization < b.organization
+		}
+		if a.workspace != b.workspace {
+			return a.workspace < b.workspace
+		}
+		if a.status != b.status {
+			return a.status < b.status
+		}
+		if a.source != b.source {
+			return a.source < b.source
+		}
+		return a.triggerReason < b.triggerReason
+	})
+
+	// Phases are sorted in the order they run, not by name.
+	phases := make([]runPhase, 0, len(t.histograms))
+	for key := range t.histograms {
+		if !scraped[key.organization] {
+			continue
+		}
+		if _, ok := t.recordedAt[runWorkspace{organization: key.organization, workspace: key.workspace}]; !ok {
+			delete(t.histograms, key)
+			continue
+		}
+		phases = append(phases, key)
+	}
+	sort.Slice(phases, func(i, j int) bool {
+		a, b := phases[i], phases[j]
+		if a.organization != b.organization {
+			return a.organization < b.organization
+		}
+		if a.workspace != b.workspace {
+			return a.workspace < b.workspace
+		}
+		return a.phase == "plan" && b.phase != "plan"
+	})
+
+	metrics := make([]prometheus.Metric, 0, len(counts)+len(phases))
+	for _, key := range counts {
+		metrics = append(metrics, prometheus.MustNewConstMetric(RunsTotal, prometheus.CounterValue, t.counts[key], key.organization, key.workspace, key.status, key.source, key.triggerReason))
+	}
+	for _, key := range phases {
+		h := t.histograms[key]
+		metrics = append(metrics, prometheus.MustNewConstHistogram(RunDuration, h.count, h.sum, h.snapshot(), key.organization, key.workspace, key.phase))
+	}
+
+	return metrics
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+// Only the config.RunsMaxPages most recent pages of runs of each workspace are read, the runs that finish between two
+// scrapes are missed when they do not fit in them.
+func (ScrapeRuns) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	totals := metricsFrom(ctx).runs
+	now := time.Now()
+	err := scrapeWorkspacesPages(ctx, config, "", func(ctx context.Context, workspaces []*workspace) error {
+		for _, w := range workspaces {
+			runs, err := listRuns(ctx, w.ID, maxInt(config.RunsMaxPages, 1), config)
+			if err != nil {
+				return err
+			}
+			totals.record(w.Organization.Name, w.Name, runs, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, m := range totals.metrics(now, config.Organizations) {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrapeRuns(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		switch r.URL.Path {
+		case "/api/v2/organizations/test-org/workspaces":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":1}
+				},
+				"data":[{
+					"id":"test-id-1",
+					"type":"workspaces",
+					"attributes":{"name":"dev"},
+					"relationships":{
+						"organization":{"data":{"id":"test-org","type":"organizations"}}
+					}
+				}]
+			}`))
+		case "/api/v2/workspaces/test-id-1/runs":
+			if r.URL.Query().Get("page[number]") != "1" {
+				t.Errorf("unexpected request for page %s of runs", r.URL.Query().Get("page[number]"))
+			}
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":2,"total-pages":2,"total-count":23}
+				},
+				"data":[{
+					"id":"run-id-1",
+					"type":"runs",
+					"attributes":{
+						"source":"tfe-api",
+						"status":"applied",
+						"trigger-reason":"manual",
+						"status-timestamps":{
+							"planning-at":"2020-10-10T10:00:00Z",
+							"planned-at":"2020-10-10T10:01:00Z",
+							"applying-at":"2020-10-10T10:02:00Z",
+							"applied-at":"2020-10-10T10:07:00Z"
+						}
+					}
+				}, {
+					"id":"run-id-2",
+					"type":"runs",
+					"attributes":{
+						"source":"tfe-api",
+						"status":"applied",
+						"trigger-reason":"manual",
+						"status-timestamps":{
+							"planning-at":"2020-10-11T10:00:00Z",
+							"planned-at":"2020-10-11T10:00:30Z",
+							"applying-at":"2020-10-11T10:01:00Z",
+							"applied-at":"2020-10-11T10:02:00Z"
+						}
+					}
+				}, {
+					"id":"run-id-3",
+					"type":"runs",
+					"attributes":{
+						"source":"tfe-configuration-version",
+						"status":"errored",
+						"trigger-reason":"unknown",
+						"status-timestamps":{
+							"planning-at":"2020-10-12T10:00:00Z",
+							"errored-at":"2020-10-12T10:00:10Z"
+						}
+					}
+				}, {
+					"id":"run-id-4",
+					"type":"runs",
+					"attributes":{
+						"source":"tfe-api",
+						"status":"planning",
+						"trigger-reason":"manual",
+						"status-timestamps":{
+							"planning-at":"2020-10-13T10:00:00Z"
+						}
+					}
+				}]
+			}`))
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}, RunsMaxPages: 1},
+	}
+
+	ctx := withMetrics(context.Background(), NewMetrics())
+
+	// The runs listed again by the second scrape are not counted twice.
+	for scrape := 1; scrape <= 2; scrape++ {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			defer close(ch)
+			if err := (ScrapeRuns{}).Scrape(ctx, config, ch); err != nil {
+				t.Errorf("error calling function on test: %s", err)
+			}
+		}()
+
+		counterExpected := []MetricResult{
+			{labels: labelMap{"organization": "test-org", "workspace": "dev", "status": "applied", "source": "tfe-api", "trigger_reason": "manual"}, value: 2, metricType: dto.MetricType_COUNTER},
+			{labels: labelMap{"organization": "test-org", "workspace": "dev", "status": "errored", "source": "tfe-configuration-version", "trigger_reason": "unknown"}, value: 1, metricType: dto.MetricType_COUNTER},
+			{labels: labelMap{"organization": "test-org", "workspace": "dev", "phase": "plan"}, value: 90, metricType: dto.MetricType_HISTOGRAM},
+			{labels: labelMap{"organization": "test-org", "workspace": "dev", "phase": "apply"}, value: 360, metricType: dto.MetricType_HISTOGRAM},
+		}
+		convey.Convey(fmt.Sprintf("Metrics comparison, scrape %d", scrape), t, func() {
+			for _, expect := range counterExpected {
+				got := readMetric(<-ch)
+				convey.So(got, convey.ShouldResemble, expect)
+			}
+			_, ok := <-ch
+			convey.So(ok, convey.ShouldBeFalse)
+		})
+	}
+}
+
+func TestRunTotals(t *testing.T) {
+	applied := []*run{{ID: "run-id-1", Status: "applied"}}
+	errored := []*run{{ID: "run-id-2", Status: "errored"}}
+	start := time.Date(2020, 10, 10, 10, 0, 0, 0, time.UTC)
+
+	convey.Convey("Run totals", t, func() {
+		totals := newRunTotals()
+		totals.record("org-a", "dev", applied, start)
+		totals.record("org-b", "dev", errored, start)
+
+		convey.Convey("only export the scraped organizations", func() {
+			metrics := totals.metrics(start, []string{"org-b"})
+			convey.So(metrics, convey.ShouldHaveLength, 1)
+			convey.So(readMetric(metrics[0]).labels["organization"], convey.ShouldEqual, "org-b")
+		})
+
+		convey.Convey("forget the workspaces the scrape did not record", func() {
+			next := start.Add(time.Minute)
+			totals.record("org-a", "prd", []*run{{ID: "run-id-3", Status: "applied"}}, next)
+
+			metrics := totals.metrics(next, []string{"org-a"})
+			convey.So(metrics, convey.ShouldHaveLength, 1)
+			convey.So(readMetric(metrics[0]).labels["workspace"], convey.ShouldEqual, "prd")
+
+			// org-b was not scraped, its series are kept.
+			convey.So(totals.counts, convey.ShouldContainKey, runCount{organization: "org-b", workspace: "dev", status: "errored"})
+		})
+	})
+}
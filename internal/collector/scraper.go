@@ -21,5 +21,6 @@ type Scraper interface {
 	Version() string
 
 	// Scrape collects data from a particular terraform cloud/enterprise API and sends it over channel as prometheus metric.
+	// The config is shared with the other scrapers of the same scrape cycle and must not be modified.
 	Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error
 }
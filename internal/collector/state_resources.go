@@ -0,0 +1,350 @@
+package collector
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// stateInventoryTTL is how long the inventory of a state version no workspace points to anymore is kept.
+	stateInventoryTTL = time.Hour
+
+	// lockFileName is the dependency lock file Terraform writes next to the configuration since 0.14.
+	lockFileName = ".terraform.lock.hcl"
+
+	// maxLockFileSize bounds the lock file read from a configuration version, they are a few kilobytes.
+	maxLockFileSize = 1 << 20
+)
+
+// Metric descriptors.
+var (
+	StateResourcesByType = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, stateSubsystem, "resources_by_type"),
+		"Number of managed resource instances in the current state of the workspace, by provider and resource type",
+		[]string{"organization", "workspace", "provider", "type"}, nil,
+	)
+	StateProviderVersionInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, stateSubsystem, "provider_version_info"),
+		"Providers used by the current state of the workspace, with the version locked by the configuration of the run that wrote it",
+		[]string{"organization", "workspace", "provider", "version"}, nil,
+	)
+)
+
+// stateResourceType identifies one tf_state_resources_by_type series of a workspace.
+type stateResourceType struct {
+	provider, typ string
+}
+
+// stateInventory is what we learn from the body of a state version and the configuration of the run that wrote it,
+// neither of which change once created.
+type stateInventory struct {
+	terraformVersion string
+	size             int64
+	resources        map[stateResourceType]int
+	// providerVersions maps the source address of the providers to the version in the dependency lock file,
+	// once lockFileRead.
+	providerVersions map[string]string
+	lockFileRead     bool
+}
+
+// managedResources returns the number of managed resource instances in the state.
+func (i *stateInventory) managedResources() int {
+	count := 0
+	for _, n := range i.resources {
+		count += n
+	}
+	return count
+}
+
+// stateInventoryCache holds the inventory of the state versions recently scraped, keyed by state version id,
+// so that a state is only downloaded again once the workspace has a new one.
+type stateInventoryCache struct {
+	mu      sync.Mutex
+	entries map[string]*stateInventoryEntry
+}
+
+type stateInventoryEntry struct {
+	inventory *stateInventory
+	usedAt    time.Time
+}
+
+var stateInventories = &stateInventoryCache{entries: map[string]*stateInventoryEntry{}}
+
+func (c *stateInventoryCache) get(id string) (*stateInventory, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	entry.usedAt = time.Now()
+	return entry.inventory, true
+}
+
+// put adds the inventory of a state version and evicts the ones not used for stateInventoryTTL.
+func (c *stateInventoryCache) put(id string, inventory *stateInventory) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.Sub(entry.usedAt) > stateInventoryTTL {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[id] = &stateInventoryEntry{inventory: inventory, usedAt: now}
+}
+
+// ScrapeStateResources scrapes the resource types and providers found in the current state of every workspace.
+type ScrapeStateResources struct{}
+
+func init() {
+	Scrapers[ScrapeStateResources{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeStateResources) Name() string {
+	return "state_resources"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeStateResources) Help() string {
+	return "Download the current state of the workspaces and the lock file of their configuration to inventory its resources and providers: https://www.terraform.io/docs/cloud/api/state-versions.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeStateResources) Version() string {
+	return "v2"
+}
+
+// providerName returns the source address of the provider of a state resource, without alias,
+// e.g. provider["registry.terraform.io/hashicorp/aws"].west becomes registry.terraform.io/hashicorp/aws.
+// States written by Terraform 0.12 only reference the provider by its local name, e.g. provider.aws.
+func providerName(provider string) string {
+	if i := strings.Index(provider, `provider["`); i >= 0 {
+		name := provider[i+len(`provider["`):]
+		if j := strings.Index(name, `"]`); j >= 0 {
+			return name[:j]
+		}
+		return name
+	}
+	if i := strings.LastIndex(provider, "provider."); i >= 0 {
+		return strings.SplitN(provider[i+len("provider."):], ".", 2)[0]
+	}
+	return provider
+}
+
+// parseLockFile returns the version of every provider locked by a dependency lock file, keyed by source address.
+func parseLockFile(src []byte, filename string) (map[string]string, error) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	content, _, diags := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "provider", LabelNames: []string{"source"}}},
+	})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	versions := map[string]string{}
+	for _, block := range content.Blocks {
+		attrs, _, diags := block.Body.PartialContent(&hcl.BodySchema{
+			Attributes: []hcl.AttributeSchema{{Name: "version"}},
+		})
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		attr, ok := attrs.Attributes["version"]
+		if !ok {
+			continue
+		}
+		var version string
+		if diags := gohcl.DecodeExpression(attr.Expr, nil, &version); diags.HasErrors() {
+			return nil, diags
+		}
+		versions[block.Labels[0]] = version
+	}
+	return versions, nil
+}
+
+// getProviderVersions downloads a configuration version and returns the provider versions of the dependency lock file
+// found in workingDirectory, or nil when the archive has none or can not be downloaded from this Terraform Enterprise release.
+func getProviderVersions(ctx context.Context, configurationVersionID, workingDirectory string, config *setup.Config) (map[string]string, error) {
+	select {
+	case stateDownloads <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-stateDownloads }()
+
+	resp, err := doAPIRequest(ctx, config, "configuration-versions/"+url.PathEscape(configurationVersionID)+"/download", nil)
+	if err == tfe.ErrResourceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	lockFile := path.Join(strings.Trim(workingDirectory, "/"), lockFileName)
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || path.Clean(header.Name) != lockFile {
+			continue
+		}
+
+		src, err := ioutil.ReadAll(io.LimitReader(archive, maxLockFileSize))
+		if err != nil {
+			return nil, err
+		}
+		return parseLockFile(src, lockFile)
+	}
+}
+
+// getStateInventory returns the inventory of a state version, downloading it unless cached. With lockFile,
+// the provider versions are read from the configuration of the run that wrote the state unless already known.
+func getStateInventory(ctx context.Context, w *workspace, sv *stateVersion, config *setup.Config, lockFile bool) (*stateInventory, error) {
+	inventory, ok := stateInventories.get(sv.ID)
+	if ok && (!lockFile || inventory.lockFileRead) {
+		return inventory, nil
+	}
+
+	if !ok {
+		inventory = &stateInventory{resources: map[stateResourceType]int{}}
+		summary, err := downloadState(ctx, sv.DownloadURL, config, func(r *stateResource) {
+			if r.Mode == "managed" {
+				inventory.resources[stateResourceType{provider: providerName(r.Provider), typ: r.Type}] += len(r.Instances)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		inventory.terraformVersion, inventory.size = summary.terraformVersion, summary.size
+	}
+
+	// States do not record the version of providers, the lock file of the configuration that was applied does.
+	// The cached inventory may be in use by another scrape, it is copied rather than updated.
+	if lockFile {
+		read := *inventory
+		if sv.Run != nil && sv.Run.ConfigurationVersion != nil {
+			versions, err := getProviderVersions(ctx, sv.Run.ConfigurationVersion.ID, w.WorkingDirectory, config)
+			if err != nil {
+				return nil, fmt.Errorf("%v, configuration_version=%s", err, sv.Run.ConfigurationVersion.ID)
+			}
+			read.providerVersions = versions
+		}
+		read.lockFileRead = true
+		inventory = &read
+	}
+
+	stateInventories.put(sv.ID, inventory)
+	return inventory, nil
+}
+
+func getStateResources(ctx context.Context, w *workspace, config *setup.Config, ch chan<- prometheus.Metric) error {
+	sv := &stateVersion{}
+	err := readAPI(ctx, config, fmt.Sprintf("workspaces/%s/current-state-version", w.ID), url.Values{"include": {"run"}}, sv)
+	if err == tfe.ErrResourceNotFound {
+		// The workspace has no state yet.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%v, workspace=%s", err, w.ID)
+	}
+	if sv.DownloadURL == "" {
+		return nil
+	}
+
+	inventory, err := getStateInventory(ctx, w, sv, config, true)
+	if err != nil {
+		return fmt.Errorf("%v, (workspace=%s, state_version=%s)", err, w.ID, sv.ID)
+	}
+
+	types := make([]stateResourceType, 0, len(inventory.resources))
+	providers := map[string]bool{}
+	for key := range inventory.resources {
+		types = append(types, key)
+		providers[key.provider] = true
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].provider != types[j].provider {
+			return types[i].provider < types[j].provider
+		}
+		return types[i].typ < types[j].typ
+	})
+	providerNames := make([]string, 0, len(providers))
+	for provider := range providers {
+		providerNames = append(providerNames, provider)
+	}
+	sort.Strings(providerNames)
+
+	metrics := []prometheus.Metric{}
+	for _, key := range types {
+		metrics = append(metrics, prometheus.MustNewConstMetric(StateResourcesByType, prometheus.GaugeValue, float64(inventory.resources[key]), w.Organization.Name, w.Name, key.provider, key.typ))
+	}
+	for _, provider := range providerNames {
+		version, ok := inventory.providerVersions[provider]
+		if !ok {
+			version = "na"
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(StateProviderVersionInfo, prometheus.GaugeValue, 1, w.Organization.Name, w.Name, provider, version))
+	}
+
+	for _, m := range metrics {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+// The states, and the configuration of the run that wrote them, are downloaded one at a time and only when the
+// workspace has a new state version.
+func (ScrapeStateResources) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	return scrapeWorkspacesPages(ctx, config, "", func(ctx context.Context, workspaces []*workspace) error {
+		for _, w := range workspaces {
+			if err := getStateResources(ctx, w, config, ch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// configurationArchive returns the tar.gz archive of a configuration version made of files, keyed by path.
+func configurationArchive(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	archive := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("error writing the configuration archive: %s", err)
+		}
+		if _, err := archive.Write([]byte(content)); err != nil {
+			t.Fatalf("error writing the configuration archive: %s", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("error writing the configuration archive: %s", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("error writing the configuration archive: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestScrapeStateResources(t *testing.T) {
+	configuration := configurationArchive(t, map[string]string{
+		"./envs/dev/main.tf": `provider "aws" {}`,
+		"./envs/dev/.terraform.lock.hcl": `# This file is maintained automatically by "terraform init".
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "3.27.0"
+  constraints = "~> 3.0"
+  hashes = [
+    "h1:Kj8F8MHbl3nATyTX+S1DdPdnLpHVlH94l3d7/oyeUYA=",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/null" {
+  version = "3.0.0"
+}
+`,
+		"./.terraform.lock.hcl": `provider "registry.terraform.io/hashicorp/aws" {
+  version = "2.70.0"
+}
+`,
+	})
+
+	downloads, configurationDownloads := 0, 0
+	var mockAPI *httptest.Server
+	mockAPI = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/organizations/test-org/workspaces":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":1}
+				},
+				"data":[{
+					"id":"test-id-1",
+					"type":"workspaces",
+					"attributes":{"name":"dev","working-directory":"envs/dev"},
+					"relationships":{"organization":{"data":{"id":"test-org","type":"organizations"}}}
+				}]
+			}`))
+		case "/api/v2/workspaces/test-id-1/current-state-version":
+			if r.URL.Query().Get("include") != "run" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{
+				"data":{
+					"id":"sv-resources-1",
+					"type":"state-versions",
+					"attributes":{
+						"serial":3,
+						"hosted-state-download-url":"` + mockAPI.URL + `/v1/object/sv-resources-1"
+					},
+					"relationships":{"run":{"data":{"id":"run-id-1","type":"runs"}}}
+				},
+				"included":[{
+					"id":"run-id-1",
+					"type":"runs",
+					"attributes":{"status":"applied"},
+					"relationships":{"configuration-version":{"data":{"id":"cv-1","type":"configuration-versions"}}}
+				}]
+			}`))
+		case "/api/v2/configuration-versions/cv-1/download":
+			configurationDownloads++
+			w.Write(configuration)
+		case "/v1/object/sv-resources-1":
+			downloads++
+			w.Write([]byte(`{
+				"version":4,
+				"terraform_version":"0.14.3",
+				"resources":[
+					{"mode":"data","type":"aws_ami","name":"ubuntu","provider":"provider[\"registry.terraform.io/hashicorp/aws\"]","instances":[{}]},
+					{"mode":"managed","type":"aws_iam_user","name":"ci","provider":"provider[\"registry.terraform.io/hashicorp/aws\"]","instances":[{},{}]},
+					{"mode":"managed","type":"aws_iam_user","name":"ops","module":"module.west","provider":"provider[\"registry.terraform.io/hashicorp/aws\"].west","instances":[{}]},
+					{"mode":"managed","type":"random_id","name":"suffix","provider":"provider[\"registry.terraform.io/hashicorp/random\"]","instances":[{}]}
+				]
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}},
+	}
+
+	counterExpected := []MetricResult{
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "provider": "registry.terraform.io/hashicorp/aws", "type": "aws_iam_user"}, value: 3, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "provider": "registry.terraform.io/hashicorp/random", "type": "random_id"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "provider": "registry.terraform.io/hashicorp/aws", "version": "3.27.0"}, value: 1, metricType: dto.MetricType_GAUGE},
+		// The provider is missing from the lock file.
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "provider": "registry.terraform.io/hashicorp/random", "version": "na"}, value: 1, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		// The second scrape is served from the cache of state versions.
+		for i := 0; i < 2; i++ {
+			ch := make(chan prometheus.Metric)
+			go func() {
+				defer close(ch)
+				if err := (ScrapeStateResources{}).Scrape(context.Background(), config, ch); err != nil {
+					t.Errorf("error calling function on test: %s", err)
+				}
+			}()
+
+			for _, expect := range counterExpected {
+				got := readMetric(<-ch)
+				convey.So(got, convey.ShouldResemble, expect)
+			}
+			_, ok := <-ch
+			convey.So(ok, convey.ShouldBeFalse)
+		}
+		convey.So(downloads, convey.ShouldEqual, 1)
+		convey.So(configurationDownloads, convey.ShouldEqual, 1)
+	})
+}
+
+func TestProviderName(t *testing.T) {
+	convey.Convey("Provider names", t, func() {
+		convey.So(providerName(`provider["registry.terraform.io/hashicorp/aws"]`), convey.ShouldEqual, "registry.terraform.io/hashicorp/aws")
+		convey.So(providerName(`provider["registry.terraform.io/hashicorp/aws"].west`), convey.ShouldEqual, "registry.terraform.io/hashicorp/aws")
+		convey.So(providerName("provider.aws"), convey.ShouldEqual, "aws")
+		convey.So(providerName("module.vpc.provider.aws.west"), convey.ShouldEqual, "aws")
+	})
+}
+
+func TestParseLockFile(t *testing.T) {
+	convey.Convey("Lock files", t, func() {
+		versions, err := parseLockFile([]byte(`provider "registry.terraform.io/hashicorp/aws" {
+  version = "3.27.0"
+}
+
+provider "registry.terraform.io/hashicorp/random" {
+  constraints = ">= 2.0"
+}
+`), lockFileName)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(versions, convey.ShouldResemble, map[string]string{"registry.terraform.io/hashicorp/aws": "3.27.0"})
+
+		_, err = parseLockFile([]byte(`provider "registry.terraform.io/hashicorp/aws" {`), lockFileName)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
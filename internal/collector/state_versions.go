@@ -0,0 +1,295 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// state is the Metric subsystem we use.
+	stateSubsystem = "state"
+)
+
+// Metric descriptors.
+var (
+	StateResources = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, stateSubsystem, "resources"),
+		"Number of managed resources in the current state of the workspace",
+		[]string{"organization", "workspace"}, nil,
+	)
+	StateSerial = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, stateSubsystem, "serial"),
+		"Serial of the current state of the workspace",
+		[]string{"organization", "workspace"}, nil,
+	)
+	StateSize = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, stateSubsystem, "size_bytes"),
+		"Size of the current state of the workspace",
+		[]string{"organization", "workspace"}, nil,
+	)
+	StateCreated = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, stateSubsystem, "created_timestamp_seconds"),
+		"Unix timestamp at which the current state of the workspace was created",
+		[]string{"organization", "workspace"}, nil,
+	)
+)
+
+// stateVersion holds the attributes of a state version we need that are not modelled by the go-tfe StateVersion struct.
+type stateVersion struct {
+	ID                 string                  `jsonapi:"primary,state-versions"`
+	CreatedAt          time.Time               `jsonapi:"attr,created-at,iso8601"`
+	DownloadURL        string                  `jsonapi:"attr,hosted-state-download-url"`
+	Serial             int64                   `jsonapi:"attr,serial"`
+	Size               int64                   `jsonapi:"attr,size"`
+	ResourcesProcessed bool                    `jsonapi:"attr,resources-processed"`
+	Resources          []*stateVersionResource `jsonapi:"attr,resources"`
+	Run                *tfe.Run                `jsonapi:"relation,run"`
+}
+
+// stateVersionResource summarizes the instances of a resource, as reported by the API once the state is processed.
+type stateVersionResource struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// ScrapeStateVersions scrapes metrics about the current state version of every workspace.
+type ScrapeStateVersions struct{}
+
+func init() {
+	Scrapers[ScrapeStateVersions{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeStateVersions) Name() string {
+	return "state_versions"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeStateVersions) Help() string {
+	return "Scrape the current state of the workspaces from the State Versions API: https://www.terraform.io/docs/cloud/api/state-versions.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeStateVersions) Version() string {
+	return "v2"
+}
+
+// stateResource is a resource of a Terraform state, without the attributes of its instances.
+type stateResource struct {
+	Mode      string     `json:"mode"`
+	Type      string     `json:"type"`
+	Provider  string     `json:"provider"`
+	Instances []struct{} `json:"instances"`
+}
+
+// stateSummary is what we learn from reading a state body.
+type stateSummary struct {
+	terraformVersion string
+	size             int64
+}
+
+// stateDownloads is held while a state or a configuration version is downloaded, so that at most one is read at a time
+// across the state_versions and state_resources collectors, however large they are.
+var stateDownloads = make(chan struct{}, 1)
+
+// downloadState streams the state at url and calls fn for each of its resources, without keeping the state in memory.
+func downloadState(ctx context.Context, url string, config *setup.Config, fn func(*stateResource)) (*stateSummary, error) {
+	select {
+	case stateDownloads <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-stateDownloads }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := config.ClientConfig.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status code %d downloading state", resp.StatusCode)
+	}
+
+	body := &countingReader{r: resp.Body}
+	terraformVersion, err := decodeState(body, fn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(ioutil.Discard, body); err != nil {
+		return nil, err
+	}
+
+	return &stateSummary{terraformVersion: terraformVersion, size: body.n}, nil
+}
+
+// decodeState decodes a Terraform state token by token, calls fn for each of its resources
+// and returns the version of Terraform that wrote it.
+func decodeState(r io.Reader, fn func(*stateResource)) (string, error) {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return "", err
+	}
+
+	terraformVersion := ""
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case "terraform_version":
+			if err := decoder.Decode(&terraformVersion); err != nil {
+				return "", err
+			}
+		case "resources":
+			if err := expectDelim(decoder, '['); err != nil {
+				return "", err
+			}
+			for decoder.More() {
+				// The attributes of the instances are decoded into empty structs, only their number is kept.
+				resource := &stateResource{}
+				if err := decoder.Decode(resource); err != nil {
+					return "", err
+				}
+				fn(resource)
+			}
+			if err := expectDelim(decoder, ']'); err != nil {
+				return "", err
+			}
+		default:
+			if err := skipValue(decoder); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return terraformVersion, nil
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("invalid state, expected %q but got %v", delim, token)
+	}
+	return nil
+}
+
+// skipValue consumes the next value from the decoder without holding it in memory.
+func skipValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// getStateVersion sends the metrics of the current state version of a workspace.
+func getStateVersion(ctx context.Context, w *workspace, config *setup.Config, ch chan<- prometheus.Metric) error {
+	sv := &stateVersion{}
+	err := readAPI(ctx, config, fmt.Sprintf("workspaces/%s/current-state-version", w.ID), nil, sv)
+	if err == tfe.ErrResourceNotFound {
+		// The workspace has no state yet.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%v, workspace=%s", err, w.ID)
+	}
+
+	metrics := []prometheus.Metric{
+		prometheus.MustNewConstMetric(StateSerial, prometheus.GaugeValue, float64(sv.Serial), w.Organization.Name, w.Name),
+		prometheus.MustNewConstMetric(StateCreated, prometheus.GaugeValue, timestampSeconds(sv.CreatedAt), w.Organization.Name, w.Name),
+	}
+
+	resources, size := -1, sv.Size
+	if sv.ResourcesProcessed {
+		resources = 0
+		for _, r := range sv.Resources {
+			resources += r.Count
+		}
+	}
+	if config.StateVersionsDownload && sv.DownloadURL != "" && (resources < 0 || size == 0) {
+		// The state is only downloaded again once the workspace has a new state version.
+		inventory, err := getStateInventory(ctx, w, sv, config, false)
+		if err != nil {
+			return fmt.Errorf("%v, (workspace=%s, state_version=%s)", err, w.ID, sv.ID)
+		}
+		resources, size = inventory.managedResources(), inventory.size
+	}
+
+	if resources >= 0 {
+		metrics = append(metrics, prometheus.MustNewConstMetric(StateResources, prometheus.GaugeValue, float64(resources), w.Organization.Name, w.Name))
+	}
+	if size > 0 {
+		metrics = append(metrics, prometheus.MustNewConstMetric(StateSize, prometheus.GaugeValue, float64(size), w.Organization.Name, w.Name))
+	}
+
+	for _, m := range metrics {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+func (ScrapeStateVersions) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	return scrapeWorkspacesPages(ctx, config, "", func(ctx context.Context, workspaces []*workspace) error {
+		for _, w := range workspaces {
+			if err := getStateVersion(ctx, w, config, ch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const testState = `{
+	"version":4,
+	"serial":7,
+	"outputs":{"ip":{"value":"10.0.0.1","type":"string"}},
+	"resources":[
+		{"mode":"data","type":"aws_ami","name":"ubuntu","instances":[{"attributes":{"id":"ami-1"}}]},
+		{"mode":"managed","type":"aws_instance","name":"web","instances":[{"index_key":0,"attributes":{"id":"i-1"}},{"index_key":1,"attributes":{"id":"i-2"}}]},
+		{"mode":"managed","type":"aws_eip","name":"web","instances":[{"attributes":{"id":"eip-1"}}]}
+	]
+}`
+
+func TestScrapeStateVersions(t *testing.T) {
+	var mockAPI *httptest.Server
+	mockAPI = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/organizations/test-org/workspaces":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":3}
+				},
+				"data":[{
+					"id":"test-id-1",
+					"type":"workspaces",
+					"attributes":{"name":"dev"},
+					"relationships":{"organization":{"data":{"id":"test-org","type":"organizations"}}}
+				}, {
+					"id":"test-id-2",
+					"type":"workspaces",
+					"attributes":{"name":"stg"},
+					"relationships":{"organization":{"data":{"id":"test-org","type":"organizations"}}}
+				}, {
+					"id":"test-id-3",
+					"type":"workspaces",
+					"attributes":{"name":"prd"},
+					"relationships":{"organization":{"data":{"id":"test-org","type":"organizations"}}}
+				}]
+			}`))
+		case "/api/v2/workspaces/test-id-1/current-state-version":
+			w.Write([]byte(`{"data":{
+				"id":"sv-1",
+				"type":"state-versions",
+				"attributes":{
+					"created-at":"2020-10-10T10:10:10.101Z",
+					"serial":12,
+					"size":4096,
+					"resources-processed":true,
+					"resources":[
+						{"name":"web","type":"aws_instance","count":3},
+						{"name":"db","type":"aws_db_instance","count":1}
+					]
+				}
+			}}`))
+		case "/api/v2/workspaces/test-id-2/current-state-version":
+			w.Write([]byte(`{"data":{
+				"id":"sv-2",
+				"type":"state-versions",
+				"attributes":{
+					"created-at":"2020-10-10T10:10:10.101Z",
+					"serial":7,
+					"hosted-state-download-url":"` + mockAPI.URL + `/v1/object/sv-2"
+				}
+			}}`))
+		case "/v1/object/sv-2":
+			w.Write([]byte(testState))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}, StateVersionsDownload: true},
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		defer close(ch)
+		if err = (ScrapeStateVersions{}).Scrape(context.Background(), config, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+	}()
+
+	counterExpected := []MetricResult{
+		{labels: labelMap{"organization": "test-org", "workspace": "dev"}, value: 12, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev"}, value: 1602324610.101, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev"}, value: 4, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev"}, value: 4096, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg"}, value: 7, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg"}, value: 1602324610.101, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg"}, value: 3, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg"}, value: float64(len(testState)), metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range counterExpected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric descriptors.
+var (
+	WorkspaceTeamAccess = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "workspace", "team_access"),
+		"Access granted to the team on the workspace",
+		[]string{"organization", "workspace", "team", "access"}, nil,
+	)
+)
+
+// ScrapeTeamAccess scrapes the access granted to teams on every workspace.
+type ScrapeTeamAccess struct{}
+
+func init() {
+	Scrapers[ScrapeTeamAccess{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeTeamAccess) Name() string {
+	return "team_access"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeTeamAccess) Help() string {
+	return "Scrape the access of teams to workspaces from the Team Access API: https://www.terraform.io/docs/cloud/api/team-access.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeTeamAccess) Version() string {
+	return "v2"
+}
+
+// listTeamAccess pages through the Team Access API and returns every access granted on a workspace.
+func listTeamAccess(ctx context.Context, workspaceID string, config *setup.Config) ([]*tfe.TeamAccess, error) {
+	access := []*tfe.TeamAccess{}
+	for page := 1; ; page++ {
+		accessList, err := config.Client.TeamAccess.List(ctx, tfe.TeamAccessListOptions{
+			ListOptions: tfe.ListOptions{
+				PageSize:   config.PageSize,
+				PageNumber: page,
+			},
+			WorkspaceID: &workspaceID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%v, (workspace=%s, page=%d)", err, workspaceID, page)
+		}
+
+		access = append(access, accessList.Items...)
+		if accessList.Pagination == nil || page >= accessList.Pagination.TotalPages {
+			return access, nil
+		}
+	}
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+// Every audited workspace costs an extra request, config.TeamAccessWorkspacesPattern bounds which ones are.
+func (ScrapeTeamAccess) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	// The grants only reference teams by id, their names are listed once per organization first.
+	var mu sync.Mutex
+	teamNames := map[string]string{}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for _, name := range config.Organizations {
+		name := name
+		g.Go(func() error {
+			teams, err := listTeams(gctx, name, config)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, t := range teams {
+				teamNames[t.ID] = t.Name
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	return scrapeWorkspacesPages(ctx, config, "", func(ctx context.Context, workspaces []*workspace) error {
+		for _, w := range workspaces {
+			if config.TeamAccessWorkspacesPattern != nil && !config.TeamAccessWorkspacesPattern.MatchString(w.Name) {
+				continue
+			}
+
+			access, err := listTeamAccess(ctx, w.ID, config)
+			if err != nil {
+				return err
+			}
+
+			for _, a := range access {
+				if a.Team == nil {
+					continue
+				}
+				team, ok := teamNames[a.Team.ID]
+				if !ok {
+					// The team was created after the teams were listed.
+					team = a.Team.ID
+				}
+
+				select {
+				case ch <- prometheus.MustNewConstMetric(WorkspaceTeamAccess, prometheus.GaugeValue, 1, w.Organization.Name, w.Name, team, string(a.Access)):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrapeTeamAccess(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/organizations/test-org/teams":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":2}
+				},
+				"data":[{
+					"id":"team-1",
+					"type":"teams",
+					"attributes":{"name":"owners"}
+				}, {
+					"id":"team-2",
+					"type":"teams",
+					"attributes":{"name":"developers"}
+				}]
+			}`))
+		case "/api/v2/organizations/test-org/workspaces":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":2}
+				},
+				"data":[{
+					"id":"test-id-1",
+					"type":"workspaces",
+					"attributes":{"name":"prod-network"},
+					"relationships":{
+						"organization":{"data":{"id":"test-org","type":"organizations"}}
+					}
+				}, {
+					"id":"test-id-2",
+					"type":"workspaces",
+					"attributes":{"name":"sandbox"},
+					"relationships":{
+						"organization":{"data":{"id":"test-org","type":"organizations"}}
+					}
+				}]
+			}`))
+		case "/api/v2/team-workspaces":
+			if r.URL.Query().Get("filter[workspace][id]") != "test-id-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":2}
+				},
+				"data":[{
+					"id":"tws-1",
+					"type":"team-workspaces",
+					"attributes":{"access":"admin"},
+					"relationships":{
+						"team":{"data":{"id":"team-1","type":"teams"}},
+						"workspace":{"data":{"id":"test-id-1","type":"workspaces"}}
+					}
+				}, {
+					"id":"tws-2",
+					"type":"team-workspaces",
+					"attributes":{"access":"plan"},
+					"relationships":{
+						"team":{"data":{"id":"team-2","type":"teams"}},
+						"workspace":{"data":{"id":"test-id-1","type":"workspaces"}}
+					}
+				}]
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:                      *client,
+		ClientConfig:                clientConfig,
+		CLI:                         setup.CLI{Organizations: []string{"test-org"}},
+		TeamAccessWorkspacesPattern: regexp.MustCompile("^prod-"),
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		defer close(ch)
+		if err = (ScrapeTeamAccess{}).Scrape(context.Background(), config, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+	}()
+
+	counterExpected := []MetricResult{
+		{labels: labelMap{"organization": "test-org", "workspace": "prod-network", "team": "owners", "access": "admin"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "prod-network", "team": "developers", "access": "plan"}, value: 1, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range counterExpected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// teams is the Metric subsystem we use.
+	teamsSubsystem = "teams"
+)
+
+// Metric descriptors.
+var (
+	TeamsInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, teamsSubsystem, "info"),
+		"Information about existing teams",
+		[]string{"organization", "id", "team", "visibility"}, nil,
+	)
+	TeamMembers = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "team", "members"),
+		"Number of users in the team",
+		[]string{"organization", "team"}, nil,
+	)
+	TeamOrganizationAccess = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "team", "organization_access"),
+		"Whether the team is granted the organization wide access (1) or not (0)",
+		[]string{"organization", "team", "access"}, nil,
+	)
+)
+
+// ScrapeTeams scrapes metrics about the teams.
+type ScrapeTeams struct{}
+
+func init() {
+	Scrapers[ScrapeTeams{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeTeams) Name() string {
+	return teamsSubsystem
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeTeams) Help() string {
+	return "Scrape information from the Teams API: https://www.terraform.io/docs/cloud/api/teams.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeTeams) Version() string {
+	return "v2"
+}
+
+// listTeams pages through the Teams API and returns every team of an organization.
+func listTeams(ctx context.Context, organization string, config *setup.Config) ([]*tfe.Team, error) {
+	teams := []*tfe.Team{}
+	for page := 1; ; page++ {
+		teamsList, err := config.Client.Teams.List(ctx, organization, tfe.TeamListOptions{
+			ListOptions: tfe.ListOptions{
+				PageSize:   config.PageSize,
+				PageNumber: page,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%v, (organization=%s, page=%d)", err, organization, page)
+		}
+
+		teams = append(teams, teamsList.Items...)
+		if teamsList.Pagination == nil || page >= teamsList.Pagination.TotalPages {
+			return teams, nil
+		}
+	}
+}
+
+func getTeams(ctx context.Context, organization string, config *setup.Config, ch chan<- prometheus.Metric) error {
+	teams, err := listTeams(ctx, organization, config)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range teams {
+		access := tfe.OrganizationAccess{}
+		if t.OrganizationAccess != nil {
+			access = *t.OrganizationAccess
+		}
+
+		for _, m := range []prometheus.Metric{
+			prometheus.MustNewConstMetric(TeamsInfo, prometheus.GaugeValue, 1, organization, t.ID, t.Name, t.Visibility),
+			prometheus.MustNewConstMetric(TeamMembers, prometheus.GaugeValue, float64(t.UserCount), organization, t.Name),
+			prometheus.MustNewConstMetric(TeamOrganizationAccess, prometheus.GaugeValue, boolToFloat(access.ManageWorkspaces), organization, t.Name, "manage_workspaces"),
+			prometheus.MustNewConstMetric(TeamOrganizationAccess, prometheus.GaugeValue, boolToFloat(access.ManagePolicies), organization, t.Name, "manage_policies"),
+			prometheus.MustNewConstMetric(TeamOrganizationAccess, prometheus.GaugeValue, boolToFloat(access.ManageVCSSettings), organization, t.Name, "manage_vcs"),
+		} {
+			select {
+			case ch <- m:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	return nil
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+func (ScrapeTeams) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, name := range config.Organizations {
+		name := name
+		g.Go(func() error {
+			return getTeams(ctx, name, config, ch)
+		})
+	}
+
+	return g.Wait()
+}
@@ -0,0 +1,196 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// token is the Metric subsystem we use.
+	tokenSubsystem = "token"
+)
+
+// Metric descriptors.
+var (
+	TokenExpiry = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, tokenSubsystem, "expiry_timestamp_seconds"),
+		"Time at which the API token expires, in seconds since the Unix epoch",
+		[]string{"organization", "type", "owner", "token"}, nil,
+	)
+	TokenLastUsed = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, tokenSubsystem, "last_used_timestamp_seconds"),
+		"Time at which the API token was last used, in seconds since the Unix epoch",
+		[]string{"organization", "type", "owner", "token"}, nil,
+	)
+)
+
+// authenticationToken holds the attributes of an organization, team or user token, including the expiry not modelled by go-tfe.
+type authenticationToken struct {
+	ID          string    `jsonapi:"primary,authentication-tokens"`
+	CreatedAt   time.Time `jsonapi:"attr,created-at,iso8601"`
+	Description string    `jsonapi:"attr,description"`
+	LastUsedAt  time.Time `jsonapi:"attr,last-used-at,iso8601"`
+	ExpiredAt   time.Time `jsonapi:"attr,expired-at,iso8601"`
+}
+
+// ScrapeTokens scrapes the expiry and last use of the API tokens.
+type ScrapeTokens struct{}
+
+func init() {
+	Scrapers[ScrapeTokens{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeTokens) Name() string {
+	return "tokens"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeTokens) Help() string {
+	return "Scrape the expiry of organization, team and user tokens: https://www.terraform.io/docs/cloud/api/organization-tokens.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeTokens) Version() string {
+	return "v2"
+}
+
+// readAuthenticationToken reads the token at path, it returns nil when there is none or the API token is not allowed to see it.
+func readAuthenticationToken(ctx context.Context, config *setup.Config, path string) (*authenticationToken, error) {
+	t := &authenticationToken{}
+	err := readAPI(ctx, config, path, nil, t)
+	if err == tfe.ErrResourceNotFound || err == errForbidden {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// sendToken sends the metrics of a token, the timestamps it does not have (e.g. tokens that never expire) are skipped.
+func sendToken(ctx context.Context, t *authenticationToken, organization, tokenType, owner string, ch chan<- prometheus.Metric) error {
+	metrics := []prometheus.Metric{}
+	if !t.ExpiredAt.IsZero() {
+		metrics = append(metrics, prometheus.MustNewConstMetric(TokenExpiry, prometheus.GaugeValue, timestampSeconds(t.ExpiredAt), organization, tokenType, owner, t.ID))
+	}
+	if !t.LastUsedAt.IsZero() {
+		metrics = append(metrics, prometheus.MustNewConstMetric(TokenLastUsed, prometheus.GaugeValue, timestampSeconds(t.LastUsedAt), organization, tokenType, owner, t.ID))
+	}
+
+	for _, m := range metrics {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// getOrganizationTokens sends the metrics of the organization token and of the token of every team of the organization.
+func getOrganizationTokens(ctx context.Context, organization string, config *setup.Config, ch chan<- prometheus.Metric) error {
+	t, err := readAuthenticationToken(ctx, config, "organizations/"+url.PathEscape(organization)+"/authentication-token")
+	if err != nil {
+		return fmt.Errorf("%v, organization=%s", err, organization)
+	}
+	if t != nil {
+		if err := sendToken(ctx, t, organization, "organization", organization, ch); err != nil {
+			return err
+		}
+	}
+
+	teams, err := listTeams(ctx, organization, config)
+	if err != nil {
+		return err
+	}
+
+	for _, team := range teams {
+		t, err := readAuthenticationToken(ctx, config, "teams/"+url.PathEscape(team.ID)+"/authentication-token")
+		if err != nil {
+			return fmt.Errorf("%v, (organization=%s, team=%s)", err, organization, team.Name)
+		}
+		if t == nil {
+			continue
+		}
+		if err := sendToken(ctx, t, organization, "team", team.Name, ch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getUserTokens sends the metrics of the tokens of the user the API token belongs to.
+// Organization and team tokens authenticate as service accounts, which have no user tokens.
+func getUserTokens(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	user, err := config.Client.Users.ReadCurrent(ctx)
+	if err != nil {
+		return err
+	}
+	if user.IsServiceAccount {
+		return nil
+	}
+
+	for page := 1; ; page++ {
+		items, pagination, err := listAPI(ctx, config, "users/"+url.PathEscape(user.ID)+"/authentication-tokens", pageQuery(page, config), reflect.TypeOf(&authenticationToken{}))
+		if err == tfe.ErrResourceNotFound || err == errForbidden {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%v, (user=%s, page=%d)", err, user.Username, page)
+		}
+
+		for _, item := range items {
+			if err := sendToken(ctx, item.(*authenticationToken), "", "user", user.Username, ch); err != nil {
+				return err
+			}
+		}
+		if page >= pagination.TotalPages {
+			return nil
+		}
+	}
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+// User tokens and the token of the exporter, given by config.APITokenID, do not belong to an organization.
+func (ScrapeTokens) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, name := range config.Organizations {
+		name := name
+		g.Go(func() error {
+			return getOrganizationTokens(ctx, name, config, ch)
+		})
+	}
+
+	g.Go(func() error {
+		return getUserTokens(ctx, config, ch)
+	})
+
+	if config.APITokenID != "" {
+		g.Go(func() error {
+			t, err := readAuthenticationToken(ctx, config, "authentication-tokens/"+url.PathEscape(config.APITokenID))
+			if err != nil {
+				return fmt.Errorf("%v, token=%s", err, config.APITokenID)
+			}
+			if t == nil {
+				return fmt.Errorf("API token %s not found", config.APITokenID)
+			}
+			return sendToken(ctx, t, "", "exporter", "exporter", ch)
+		})
+	}
+
+	return g.Wait()
+}
@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrapeTokens(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/account/details":
+			w.Write([]byte(`{
+				"data":{
+					"id":"user-1",
+					"type":"users",
+					"attributes":{"username":"api-team_123","is-service-account":true}
+				}
+			}`))
+		case "/api/v2/organizations/test-org/authentication-token":
+			w.Write([]byte(`{
+				"data":{
+					"id":"at-org",
+					"type":"authentication-tokens",
+					"attributes":{
+						"created-at":"2021-01-01T00:00:00.000Z",
+						"last-used-at":"2021-03-01T12:00:00.000Z",
+						"expired-at":"2022-01-01T00:00:00.000Z"
+					}
+				}
+			}`))
+		case "/api/v2/organizations/test-org/teams":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":3}
+				},
+				"data":[{
+					"id":"team-1",
+					"type":"teams",
+					"attributes":{"name":"ci"}
+				}, {
+					"id":"team-2",
+					"type":"teams",
+					"attributes":{"name":"developers"}
+				}, {
+					"id":"team-3",
+					"type":"teams",
+					"attributes":{"name":"owners"}
+				}]
+			}`))
+		case "/api/v2/teams/team-1/authentication-token":
+			w.Write([]byte(`{
+				"data":{
+					"id":"at-team",
+					"type":"authentication-tokens",
+					"attributes":{
+						"created-at":"2021-01-01T00:00:00.000Z",
+						"last-used-at":null,
+						"expired-at":"2021-06-01T00:00:00.000Z"
+					}
+				}
+			}`))
+		case "/api/v2/teams/team-3/authentication-token":
+			// The API token is not allowed to see the token of the owners team.
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}},
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		defer close(ch)
+		if err = (ScrapeTokens{}).Scrape(context.Background(), config, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+	}()
+
+	counterExpected := []MetricResult{
+		{labels: labelMap{"organization": "test-org", "type": "organization", "owner": "test-org", "token": "at-org"}, value: 1640995200, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "type": "organization", "owner": "test-org", "token": "at-org"}, value: 1614600000, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "type": "team", "owner": "ci", "token": "at-team"}, value: 1622505600, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range counterExpected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
+
+func TestScrapeTokensUnauthorized(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/account/details":
+			w.Write([]byte(`{
+				"data":{
+					"id":"user-1",
+					"type":"users",
+					"attributes":{"username":"api-team_123","is-service-account":true}
+				}
+			}`))
+		default:
+			// Unlike a forbidden token, an invalid API token fails the scrape.
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org"}},
+	}
+
+	convey.Convey("Unauthorized requests", t, func() {
+		err := (ScrapeTokens{}).Scrape(context.Background(), config, make(chan prometheus.Metric, 10))
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, tfe.ErrUnauthorized.Error())
+	})
+}
@@ -0,0 +1,205 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric descriptors.
+var (
+	WorkspaceVariables = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "workspace", "variables"),
+		"Number of variables of the workspace by category and whether they are sensitive or HCL",
+		[]string{"organization", "workspace", "category", "sensitive", "hcl"}, nil,
+	)
+	WorkspaceVariablesSuspectedSecret = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "workspace", "variables_suspected_secret"),
+		"Non-sensitive variable of the workspace whose key looks like a secret",
+		[]string{"organization", "workspace", "category", "key"}, nil,
+	)
+	VariableSetInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "variable_set", "info"),
+		"Information about the variable set, global sets are applied to every workspace of the organization",
+		[]string{"organization", "id", "name", "global"}, nil,
+	)
+	VariableSetWorkspaces = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "variable_set", "workspaces"),
+		"Number of workspaces the variable set is applied to, not exported for global sets",
+		[]string{"organization", "id", "name"}, nil,
+	)
+)
+
+// variable holds the attributes of a workspace variable we export.
+// Its value is deliberately not modelled, so that it is never decoded, exported or logged.
+type variable struct {
+	ID        string `jsonapi:"primary,vars"`
+	Key       string `jsonapi:"attr,key"`
+	Category  string `jsonapi:"attr,category"`
+	HCL       bool   `jsonapi:"attr,hcl"`
+	Sensitive bool   `jsonapi:"attr,sensitive"`
+}
+
+// variableSet is a variable set of an organization with the workspaces it is applied to.
+type variableSet struct {
+	ID         string           `jsonapi:"primary,varsets"`
+	Name       string           `jsonapi:"attr,name"`
+	Global     bool             `jsonapi:"attr,global"`
+	Workspaces []*tfe.Workspace `jsonapi:"relation,workspaces"`
+}
+
+// variableCount identifies one tf_workspace_variables series of a workspace.
+type variableCount struct {
+	category       string
+	sensitive, hcl bool
+}
+
+// ScrapeVariables scrapes metrics about the variables of every workspace and the variable sets.
+type ScrapeVariables struct{}
+
+func init() {
+	Scrapers[ScrapeVariables{}] = false
+}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeVariables) Name() string {
+	return "variables"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeVariables) Help() string {
+	return "Scrape the variables of workspaces and the variable sets, never their values: https://www.terraform.io/docs/cloud/api/workspace-variables.html"
+}
+
+// Version of Terraform Cloud/Enterprise API from which scraper is available.
+func (ScrapeVariables) Version() string {
+	return "v2"
+}
+
+// listVariables pages through the Workspace Variables API and returns every variable of a workspace.
+func listVariables(ctx context.Context, workspaceID string, config *setup.Config) ([]*variable, error) {
+	variables := []*variable{}
+	for page := 1; ; page++ {
+		items, pagination, err := listAPI(ctx, config, "workspaces/"+url.PathEscape(workspaceID)+"/vars", pageQuery(page, config), reflect.TypeOf(&variable{}))
+		if err != nil {
+			return nil, fmt.Errorf("%v, (workspace=%s, page=%d)", err, workspaceID, page)
+		}
+
+		for _, item := range items {
+			variables = append(variables, item.(*variable))
+		}
+		if page >= pagination.TotalPages {
+			return variables, nil
+		}
+	}
+}
+
+func getVariableSets(ctx context.Context, organization string, config *setup.Config, ch chan<- prometheus.Metric) error {
+	for page := 1; ; page++ {
+		items, pagination, err := listAPI(ctx, config, "organizations/"+url.PathEscape(organization)+"/varsets", pageQuery(page, config), reflect.TypeOf(&variableSet{}))
+		if err == tfe.ErrResourceNotFound {
+			// Terraform Enterprise releases without variable sets.
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%v, (organization=%s, page=%d)", err, organization, page)
+		}
+
+		metrics := []prometheus.Metric{}
+		for _, item := range items {
+			s := item.(*variableSet)
+			metrics = append(metrics, prometheus.MustNewConstMetric(VariableSetInfo, prometheus.GaugeValue, 1, organization, s.ID, s.Name, strconv.FormatBool(s.Global)))
+			if !s.Global {
+				metrics = append(metrics, prometheus.MustNewConstMetric(VariableSetWorkspaces, prometheus.GaugeValue, float64(len(s.Workspaces)), organization, s.ID, s.Name))
+			}
+		}
+
+		for _, m := range metrics {
+			select {
+			case ch <- m:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if page >= pagination.TotalPages {
+			return nil
+		}
+	}
+}
+
+func getWorkspaceVariables(ctx context.Context, w *workspace, config *setup.Config, ch chan<- prometheus.Metric) error {
+	variables, err := listVariables(ctx, w.ID, config)
+	if err != nil {
+		return err
+	}
+
+	counts := map[variableCount]int{}
+	metrics := []prometheus.Metric{}
+	for _, v := range variables {
+		counts[variableCount{category: v.Category, sensitive: v.Sensitive, hcl: v.HCL}]++
+		if !v.Sensitive && config.VariablesSecretKeysPattern != nil && config.VariablesSecretKeysPattern.MatchString(v.Key) {
+			metrics = append(metrics, prometheus.MustNewConstMetric(WorkspaceVariablesSuspectedSecret, prometheus.GaugeValue, 1, w.Organization.Name, w.Name, v.Category, v.Key))
+		}
+	}
+
+	// Every combination is sent, so that a workspace without sensitive variables reports 0 rather than nothing.
+	for _, category := range []string{string(tfe.CategoryTerraform), string(tfe.CategoryEnv)} {
+		for _, sensitive := range []bool{false, true} {
+			for _, hcl := range []bool{false, true} {
+				metrics = append(metrics, prometheus.MustNewConstMetric(
+					WorkspaceVariables,
+					prometheus.GaugeValue,
+					float64(counts[variableCount{category: category, sensitive: sensitive, hcl: hcl}]),
+					w.Organization.Name,
+					w.Name,
+					category,
+					strconv.FormatBool(sensitive),
+					strconv.FormatBool(hcl),
+				))
+			}
+		}
+	}
+
+	for _, m := range metrics {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Scrape collects data from Terraform API and sends it over channel as prometheus metric.
+func (ScrapeVariables) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
+	g, gctx := errgroup.WithContext(ctx)
+	for _, name := range config.Organizations {
+		name := name
+		g.Go(func() error {
+			return getVariableSets(gctx, name, config, ch)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	return scrapeWorkspacesPages(ctx, config, "", func(ctx context.Context, workspaces []*workspace) error {
+		for _, w := range workspaces {
+			if err := getWorkspaceVariables(ctx, w, config, ch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
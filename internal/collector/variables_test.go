@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
+
+	tfe "github.com/hashicorp/go-tfe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrapeVariables(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/organizations/test-org/varsets":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":2}
+				},
+				"data":[{
+					"id":"varset-1",
+					"type":"varsets",
+					"attributes":{"name":"aws-credentials","global":false},
+					"relationships":{
+						"workspaces":{"data":[{"id":"test-id-1","type":"workspaces"},{"id":"test-id-2","type":"workspaces"}]}
+					}
+				}, {
+					"id":"varset-2",
+					"type":"varsets",
+					"attributes":{"name":"defaults","global":true},
+					"relationships":{
+						"workspaces":{"data":[]}
+					}
+				}]
+			}`))
+		case "/api/v2/organizations/test-org/workspaces":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":1}
+				},
+				"data":[{
+					"id":"test-id-1",
+					"type":"workspaces",
+					"attributes":{"name":"dev"},
+					"relationships":{
+						"organization":{"data":{"id":"test-org","type":"organizations"}}
+					}
+				}]
+			}`))
+		case "/api/v2/workspaces/test-id-1/vars":
+			w.Write([]byte(`{
+				"meta":{
+					"pagination":{"current-page":1,"prev-page":null,"next-page":null,"total-pages":1,"total-count":3}
+				},
+				"data":[{
+					"id":"var-1",
+					"type":"vars",
+					"attributes":{"key":"AWS_SECRET_ACCESS_KEY","value":"do-not-export","category":"env","hcl":false,"sensitive":false}
+				}, {
+					"id":"var-2",
+					"type":"vars",
+					"attributes":{"key":"db_password","value":null,"category":"terraform","hcl":false,"sensitive":true}
+				}, {
+					"id":"var-3",
+					"type":"vars",
+					"attributes":{"key":"tags","value":"{ team = \"infra\" }","category":"terraform","hcl":true,"sensitive":false}
+				}]
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:                     *client,
+		ClientConfig:               clientConfig,
+		CLI:                        setup.CLI{Organizations: []string{"test-org"}},
+		VariablesSecretKeysPattern: regexp.MustCompile("(?i)(secret|password)"),
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		defer close(ch)
+		if err = (ScrapeVariables{}).Scrape(context.Background(), config, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+	}()
+
+	counterExpected := []MetricResult{
+		{labels: labelMap{"organization": "test-org", "id": "varset-1", "name": "aws-credentials", "global": "false"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "id": "varset-1", "name": "aws-credentials"}, value: 2, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "id": "varset-2", "name": "defaults", "global": "true"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "category": "env", "key": "AWS_SECRET_ACCESS_KEY"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "category": "terraform", "sensitive": "false", "hcl": "false"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "category": "terraform", "sensitive": "false", "hcl": "true"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "category": "terraform", "sensitive": "true", "hcl": "false"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "category": "terraform", "sensitive": "true", "hcl": "true"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "category": "env", "sensitive": "false", "hcl": "false"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "category": "env", "sensitive": "false", "hcl": "true"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "category": "env", "sensitive": "true", "hcl": "false"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "category": "env", "sensitive": "true", "hcl": "true"}, value: 0, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range counterExpected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
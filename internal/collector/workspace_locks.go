@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// workspaceLockTTL is how long the lock of a workspace that is not listed anymore, e.g. deleted while locked, is remembered.
+const workspaceLockTTL = time.Hour
+
+// workspaceLockCache remembers when each locked workspace was first seen locked by its current locker, keyed by workspace id.
+// The API does not tell when a workspace was locked, so the time is only as accurate as the scrape interval
+// and is lost when the exporter restarts.
+type workspaceLockCache struct {
+	mu      sync.Mutex
+	entries map[string]*workspaceLockEntry
+}
+
+type workspaceLockEntry struct {
+	locker string
+	since  time.Time
+	seenAt time.Time
+}
+
+var workspaceLocks = newWorkspaceLockCache()
+
+func newWorkspaceLockCache() *workspaceLockCache {
+	return &workspaceLockCache{entries: map[string]*workspaceLockEntry{}}
+}
+
+// observe records who holds the lock of the workspace, e.g. "users/user-1", and returns since when they do.
+// An empty locker means the workspace is not locked, the zero time is returned and the lock is forgotten.
+func (c *workspaceLockCache) observe(id, locker string, now time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if locker == "" {
+		delete(c.entries, id)
+		return time.Time{}
+	}
+
+	entry, ok := c.entries[id]
+	if !ok || entry.locker != locker {
+		entry = &workspaceLockEntry{locker: locker, since: now}
+		c.entries[id] = entry
+	}
+	entry.seenAt = now
+	return entry.since
+}
+
+// evict forgets the locks of the workspaces not observed for workspaceLockTTL before now.
+func (c *workspaceLockCache) evict(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if now.Sub(entry.seenAt) > workspaceLockTTL {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestWorkspaceLockCache(t *testing.T) {
+	start := time.Date(2020, 10, 10, 10, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	convey.Convey("Workspace locks", t, func() {
+		c := newWorkspaceLockCache()
+
+		convey.Convey("record the time the lock was first seen", func() {
+			convey.So(c.observe("ws-1", "users/user-1", at(0)), convey.ShouldEqual, at(0))
+			convey.So(c.observe("ws-1", "users/user-1", at(1)), convey.ShouldEqual, at(0))
+		})
+
+		convey.Convey("start over when the locker changes", func() {
+			c.observe("ws-1", "users/user-1", at(0))
+			convey.So(c.observe("ws-1", "runs/run-1", at(1)), convey.ShouldEqual, at(1))
+			convey.So(c.observe("ws-1", "runs/run-2", at(2)), convey.ShouldEqual, at(2))
+		})
+
+		convey.Convey("forget the lock on unlock", func() {
+			c.observe("ws-1", "users/user-1", at(0))
+			convey.So(c.observe("ws-1", "", at(1)).IsZero(), convey.ShouldBeTrue)
+			convey.So(c.entries, convey.ShouldBeEmpty)
+			convey.So(c.observe("ws-1", "users/user-1", at(2)), convey.ShouldEqual, at(2))
+		})
+
+		convey.Convey("evict the locks not observed for the TTL", func() {
+			c.observe("ws-1", "users/user-1", at(0))
+			c.observe("ws-2", "users/user-1", at(30))
+			c.evict(at(0).Add(workspaceLockTTL + time.Minute))
+			convey.So(c.entries, convey.ShouldContainKey, "ws-2")
+			convey.So(c.entries, convey.ShouldNotContainKey, "ws-1")
+		})
+	})
+}
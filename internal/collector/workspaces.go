@@ -3,12 +3,20 @@ package collector
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
 	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
 
 	tfe "github.com/hashicorp/go-tfe"
+	version "github.com/hashicorp/go-version"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -16,27 +24,117 @@ import (
 const (
 	// workspaces is the Metric subsystem we use.
 	workspacesSubsystem = "workspaces"
-
-	// TODO: We might want to allow the user to control pageSize via cli/config
-	// 		* This could be handy for users hitting API rate limits (30 per sec).
-	// 		* Investigate performance of (100 requests for 1 item) vs (1 request for 100 items).
-	pageSize = 20
 )
 
 // Metric descriptors.
 var (
 	WorkspacesInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, workspacesSubsystem, "info"),
+		"Information about existing workspaces",
+		[]string{"id", "name", "organization", "terraform_version", "environment", "current_run", "current_run_status"}, nil,
+	)
+	// WorkspacesInfoLegacy is sent instead of WorkspacesInfo with --compat.timestamp-labels.
+	// Deprecated: use the timestamp gauges instead of the created_at and current_run_created_at labels.
+	WorkspacesInfoLegacy = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, workspacesSubsystem, "info"),
 		"Information about existing workspaces",
 		[]string{"id", "name", "organization", "terraform_version", "created_at", "environment", "current_run", "current_run_status", "current_run_created_at"}, nil,
 	)
+	WorkspacesCreated = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, workspacesSubsystem, "created_timestamp_seconds"),
+		"Unix timestamp at which the workspace was created",
+		[]string{"id", "name", "organization"}, nil,
+	)
+	WorkspacesLatestChange = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, workspacesSubsystem, "latest_change_timestamp_seconds"),
+		"Unix timestamp of the latest change to the workspace state",
+		[]string{"id", "name", "organization"}, nil,
+	)
+	WorkspacesByTerraformVersion = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, workspacesSubsystem, "by_terraform_version"),
+		"Number of workspaces using each Terraform version",
+		[]string{"organization", "version"}, nil,
+	)
+	WorkspacesTerraformVersionCompliant = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, workspacesSubsystem, "terraform_version_compliant"),
+		"Whether the Terraform version of the workspace satisfies --terraform-version.minimum and --terraform-version.constraint (1) or not (0)",
+		[]string{"organization", "workspace", "version"}, nil,
+	)
+	WorkspaceSettingsInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "workspace", "settings_info"),
+		"Settings of the workspace",
+		[]string{"organization", "workspace", "execution_mode", "auto_apply", "queue_all_runs", "speculative_enabled", "file_triggers_enabled", "vcs_repo_identifier", "working_directory", "allow_destroy_plan", "global_remote_state"}, nil,
+	)
+	WorkspaceLocked = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "workspace", "locked"),
+		"Whether the workspace is locked (1) or not (0), by the type of what locked it: user, run or team",
+		[]string{"organization", "workspace", "locked_by_type"}, nil,
+	)
+	WorkspaceLockedSince = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "workspace", "locked_since_timestamp_seconds"),
+		"Unix timestamp at which the exporter first saw the workspace locked",
+		[]string{"organization", "workspace", "locked_by_type"}, nil,
+	)
+	WorkspacesCurrentRunCreated = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, workspacesSubsystem, "current_run_created_timestamp_seconds"),
+		"Unix timestamp at which the current run of the workspace was created",
+		[]string{"id", "name", "organization"}, nil,
+	)
 )
 
+// workspace holds the attributes of a workspace we export, including the ones not modelled by the go-tfe Workspace struct.
+type workspace struct {
+	ID               string    `jsonapi:"primary,workspaces"`
+	Name             string    `jsonapi:"attr,name"`
+	CreatedAt        time.Time `jsonapi:"attr,created-at,iso8601"`
+	Environment      string    `jsonapi:"attr,environment"`
+	LatestChangeAt   time.Time `jsonapi:"attr,latest-change-at,iso8601"`
+	TerraformVersion string    `jsonapi:"attr,terraform-version"`
+	Locked           bool      `jsonapi:"attr,locked"`
+
+	ExecutionMode       string       `jsonapi:"attr,execution-mode"`
+	Operations          bool         `jsonapi:"attr,operations"`
+	AutoApply           bool         `jsonapi:"attr,auto-apply"`
+	QueueAllRuns        bool         `jsonapi:"attr,queue-all-runs"`
+	SpeculativeEnabled  bool         `jsonapi:"attr,speculative-enabled"`
+	FileTriggersEnabled bool         `jsonapi:"attr,file-triggers-enabled"`
+	VCSRepo             *tfe.VCSRepo `jsonapi:"attr,vcs-repo"`
+	WorkingDirectory    string       `jsonapi:"attr,working-directory"`
+	AllowDestroyPlan    bool         `jsonapi:"attr,allow-destroy-plan"`
+	GlobalRemoteState   bool         `jsonapi:"attr,global-remote-state"`
+
+	Organization *tfe.Organization `jsonapi:"relation,organization"`
+	CurrentRun   *tfe.Run          `jsonapi:"relation,current-run"`
+
+	// LockedByType and LockedByID identify the resource holding the lock (users, runs or teams), read from the
+	// locked-by relationship which jsonapi can not decode as it points to resources of different types.
+	LockedByType string
+	LockedByID   string
+}
+
+// resourceIdentifier identifies the resource a relationship points to.
+type resourceIdentifier struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// workspacesDocument holds the members of a page of workspaces decoded with encoding/json.
+type workspacesDocument struct {
+	Data []struct {
+		ID            string `json:"id"`
+		Relationships struct {
+			LockedBy struct {
+				Data *resourceIdentifier `json:"data"`
+			} `json:"locked-by"`
+		} `json:"relationships"`
+	} `json:"data"`
+}
+
 // ScrapeWorkspaces scrapes metrics about the workspaces.
 type ScrapeWorkspaces struct{}
 
 func init() {
-	Scrapers = append(Scrapers, ScrapeWorkspaces{})
+	Scrapers[ScrapeWorkspaces{}] = true
 }
 
 // Name of the Scraper. Should be unique.
@@ -54,71 +152,233 @@ func (ScrapeWorkspaces) Version() string {
 	return "v2"
 }
 
-func getWorkspacesListPage(ctx context.Context, page int, organization string, config *setup.Config, ch chan<- prometheus.Metric) error {
-	include := "current_run"
-	workspacesList, err := config.Client.Workspaces.List(ctx, organization, tfe.WorkspaceListOptions{
-		ListOptions: tfe.ListOptions{
-			PageSize:   pageSize,
-			PageNumber: page,
-		},
-		Include: &include,
-	})
+// getWorkspacesPage returns a single page of the workspaces of an organization and its pagination details.
+// include lists the related resources to embed in the response, e.g. "current_run".
+func getWorkspacesPage(ctx context.Context, page int, organization, include string, config *setup.Config) ([]*workspace, *tfe.Pagination, error) {
+	query := pageQuery(page, config)
+	if include != "" {
+		query.Set("include", include)
+	}
+	doc := &workspacesDocument{}
+	items, pagination, err := listAPIRaw(ctx, config, "organizations/"+url.PathEscape(organization)+"/workspaces", query, reflect.TypeOf(&workspace{}), doc)
 	if err != nil {
-		return fmt.Errorf("%v, (organization=%s, page=%d)", err, organization, page)
+		return nil, nil, fmt.Errorf("%v, (organization=%s, page=%d)", err, organization, page)
+	}
+
+	lockedBy := map[string]*resourceIdentifier{}
+	for _, d := range doc.Data {
+		if d.Relationships.LockedBy.Data != nil {
+			lockedBy[d.ID] = d.Relationships.LockedBy.Data
+		}
+	}
+
+	workspaces := make([]*workspace, 0, len(items))
+	for _, item := range items {
+		w := item.(*workspace)
+		if w.Organization == nil {
+			w.Organization = &tfe.Organization{Name: organization}
+		}
+		if locker, ok := lockedBy[w.ID]; ok {
+			w.LockedByType, w.LockedByID = locker.Type, locker.ID
+		}
+		workspaces = append(workspaces, w)
 	}
 
-	for _, w := range workspacesList.Items {
+	return workspaces, pagination, nil
+}
+
+// pageWorkersKey is the context key of the semaphore bounding the pages of workspaces fetched by a scrape cycle.
+type pageWorkersKey struct{}
+
+// withPageWorkers returns a copy of ctx in which the scrapeWorkspacesPages calls of every scraper share a budget
+// of n pages fetched and sent at a time.
+func withPageWorkers(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, pageWorkersKey{}, make(chan struct{}, maxInt(n, 1)))
+}
+
+// scrapeWorkspacesPages pages through the workspaces of every configured organization and calls send with each page.
+// The first page tells how many more pages there are, the others are fetched concurrently.
+// At most config.PageWorkers pages, first pages included, are fetched and sent at a time across all organizations
+// and, within a scrape cycle, across all scrapers. As send runs concurrently, totals it accumulates must be guarded
+// and are complete once scrapeWorkspacesPages returns.
+func scrapeWorkspacesPages(ctx context.Context, config *setup.Config, include string, send func(ctx context.Context, workspaces []*workspace) error) error {
+	workers, ok := ctx.Value(pageWorkersKey{}).(chan struct{})
+	if !ok {
+		workers = make(chan struct{}, maxInt(config.PageWorkers, 1))
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	scrapePage := func(page int, name string) (*tfe.Pagination, error) {
 		select {
-		case ch <- prometheus.MustNewConstMetric(
-			WorkspacesInfo,
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-workers }()
+
+		workspaces, pagination, err := getWorkspacesPage(ctx, page, name, include, config)
+		if err != nil {
+			return nil, err
+		}
+		return pagination, send(ctx, workspaces)
+	}
+
+	for _, name := range config.Organizations {
+		name := name
+		g.Go(func() error {
+			pagination, err := scrapePage(1, name)
+			if err != nil || pagination == nil {
+				return err
+			}
+
+			for i := 2; i <= pagination.TotalPages; i++ {
+				page := i
+				g.Go(func() error {
+					_, err := scrapePage(page, name)
+					return err
+				})
+			}
+
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+func workspaceMetrics(w *workspace, config *setup.Config) []prometheus.Metric {
+	organization := w.Organization.Name
+	metrics := []prometheus.Metric{}
+	if config.CompatTimestampLabels {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			WorkspacesInfoLegacy,
 			prometheus.GaugeValue,
 			1,
 			w.ID,
 			w.Name,
-			w.Organization.Name,
+			organization,
 			w.TerraformVersion,
 			w.CreatedAt.String(),
 			w.Environment,
 			getCurrentRunID(w.CurrentRun),
 			getCurrentRunStatus(w.CurrentRun),
 			getCurrentRunCreatedAt(w.CurrentRun),
-		):
-		case <-ctx.Done():
-			return ctx.Err()
+		))
+	} else {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			WorkspacesInfo,
+			prometheus.GaugeValue,
+			1,
+			w.ID,
+			w.Name,
+			organization,
+			w.TerraformVersion,
+			w.Environment,
+			getCurrentRunID(w.CurrentRun),
+			getCurrentRunStatus(w.CurrentRun),
+		))
+	}
+
+	metrics = append(metrics, prometheus.MustNewConstMetric(WorkspacesCreated, prometheus.GaugeValue, timestampSeconds(w.CreatedAt), w.ID, w.Name, organization))
+	if !w.LatestChangeAt.IsZero() {
+		metrics = append(metrics, prometheus.MustNewConstMetric(WorkspacesLatestChange, prometheus.GaugeValue, timestampSeconds(w.LatestChangeAt), w.ID, w.Name, organization))
+	}
+	if len(config.TerraformVersionPolicy) > 0 {
+		// Versions that can not be parsed, e.g. "latest", are not reported on.
+		if v, err := version.NewVersion(w.TerraformVersion); err == nil {
+			compliant := 0.0
+			if config.TerraformVersionPolicy.Check(v) {
+				compliant = 1
+			}
+			metrics = append(metrics, prometheus.MustNewConstMetric(WorkspacesTerraformVersionCompliant, prometheus.GaugeValue, compliant, organization, w.Name, w.TerraformVersion))
 		}
 	}
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		WorkspaceSettingsInfo,
+		prometheus.GaugeValue,
+		1,
+		organization,
+		w.Name,
+		getExecutionMode(w),
+		strconv.FormatBool(w.AutoApply),
+		strconv.FormatBool(w.QueueAllRuns),
+		strconv.FormatBool(w.SpeculativeEnabled),
+		strconv.FormatBool(w.FileTriggersEnabled),
+		getVCSRepoIdentifier(w.VCSRepo),
+		getWorkingDirectory(w),
+		strconv.FormatBool(w.AllowDestroyPlan),
+		strconv.FormatBool(w.GlobalRemoteState),
+	))
+	if w.CurrentRun != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(WorkspacesCurrentRunCreated, prometheus.GaugeValue, timestampSeconds(w.CurrentRun.CreatedAt), w.ID, w.Name, organization))
+	}
 
-	return nil
+	// The API does not tell since when a workspace is locked, the first scrape that saw its current locker does.
+	lockedByType := strings.TrimSuffix(w.LockedByType, "s")
+	metrics = append(metrics, prometheus.MustNewConstMetric(WorkspaceLocked, prometheus.GaugeValue, boolToFloat(w.Locked), organization, w.Name, lockedByType))
+	locker := ""
+	if w.Locked {
+		locker = w.LockedByType + "/" + w.LockedByID
+	}
+	if since := workspaceLocks.observe(w.ID, locker, time.Now()); !since.IsZero() {
+		metrics = append(metrics, prometheus.MustNewConstMetric(WorkspaceLockedSince, prometheus.GaugeValue, timestampSeconds(since), organization, w.Name, lockedByType))
+	}
+
+	return metrics
+}
+
+// terraformVersionCount identifies one tf_workspaces_by_terraform_version series.
+type terraformVersionCount struct {
+	organization, version string
 }
 
 // Scrape collects data from Terraform API and sends it over channel as prometheus metric.
 func (ScrapeWorkspaces) Scrape(ctx context.Context, config *setup.Config, ch chan<- prometheus.Metric) error {
-	g, ctx := errgroup.WithContext(ctx)
-	for _, name := range config.Organizations {
-		name := name
-		g.Go(func() error {
-			// TODO: Dummy list call to get the number of workspaces.
-			//       Investigate if there is a better way to get the workspace count.
-			workspacesList, err := config.Client.Workspaces.List(ctx, name, tfe.WorkspaceListOptions{
-				ListOptions: tfe.ListOptions{PageSize: pageSize},
-			})
-			if err != nil {
-				return fmt.Errorf("%v, organization=%s", err, name)
-			}
+	var mu sync.Mutex
+	versions := map[terraformVersionCount]int{}
 
-			// TODO: We should be able to do some of this work in parallel.
-			//       Investigate potential complications before enabling it.
-			for i := 1; i <= workspacesList.Pagination.TotalPages; i++ {
-				if err := getWorkspacesListPage(ctx, i, name, config, ch); err != nil {
-					return err
+	workspaceLocks.evict(time.Now())
+
+	err := scrapeWorkspacesPages(ctx, config, "current_run", func(ctx context.Context, workspaces []*workspace) error {
+		for _, w := range workspaces {
+			mu.Lock()
+			versions[terraformVersionCount{organization: w.Organization.Name, version: w.TerraformVersion}]++
+			mu.Unlock()
+
+			for _, m := range workspaceMetrics(w, config) {
+				select {
+				case ch <- m:
+				case <-ctx.Done():
+					return ctx.Err()
 				}
 			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-			return nil
-		})
+	keys := make([]terraformVersionCount, 0, len(versions))
+	for key := range versions {
+		keys = append(keys, key)
 	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].organization != keys[j].organization {
+			return keys[i].organization < keys[j].organization
+		}
+		return keys[i].version < keys[j].version
+	})
 
-	return g.Wait()
+	for _, key := range keys {
+		select {
+		case ch <- prometheus.MustNewConstMetric(WorkspacesByTerraformVersion, prometheus.GaugeValue, float64(versions[key]), key.organization, key.version):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
 }
 
 func getCurrentRunID(r *tfe.Run) string {
@@ -144,3 +404,32 @@ func getCurrentRunCreatedAt(r *tfe.Run) string {
 
 	return r.CreatedAt.String()
 }
+
+// getExecutionMode returns the execution mode of the workspace, derived from the deprecated operations attribute
+// on Terraform Enterprise releases that do not report it.
+func getExecutionMode(w *workspace) string {
+	switch {
+	case w.ExecutionMode != "":
+		return w.ExecutionMode
+	case w.Operations:
+		return "remote"
+	default:
+		return "local"
+	}
+}
+
+func getVCSRepoIdentifier(r *tfe.VCSRepo) string {
+	if r == nil {
+		return "na"
+	}
+
+	return r.Identifier
+}
+
+func getWorkingDirectory(w *workspace) string {
+	if w.WorkingDirectory == "" {
+		return "na"
+	}
+
+	return w.WorkingDirectory
+}
@@ -2,13 +2,19 @@ package collector
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
 
 	tfe "github.com/hashicorp/go-tfe"
+	version "github.com/hashicorp/go-version"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
@@ -31,7 +37,16 @@ func TestScrapeWorkspaces(t *testing.T) {
 					"created-at":"1010-10-10T10:10:10.101Z",
 					"environment":"test-environment",
 					"terraform-version":"0.14.3",
-					"latest-change-at":"2020-10-10T10:10:10.101Z"
+					"latest-change-at":"2020-10-10T10:10:10.101Z",
+					"execution-mode":"agent",
+					"auto-apply":true,
+					"queue-all-runs":false,
+					"speculative-enabled":true,
+					"file-triggers-enabled":true,
+					"vcs-repo":{"branch":"main","identifier":"example/infra"},
+					"working-directory":"envs/dev",
+					"allow-destroy-plan":true,
+					"global-remote-state":false
 				},
 				"relationships":{
 					"organization":{"data":{"id":"test-org","type":"organizations"}},
@@ -54,29 +69,45 @@ func TestScrapeWorkspaces(t *testing.T) {
 					"created-at":"1010-10-10T10:10:10.101Z",
 					"environment":"test-environment",
 					"terraform-version":"0.14.2",
-					"latest-change-at":"2020-10-10T10:10:10.101Z"
+					"latest-change-at":"2020-10-10T10:10:10.101Z",
+					"locked":true
 				},
 				"relationships":{
-					"organization":{"data":{"id":"test-org","type":"organizations"}}
+					"organization":{"data":{"id":"test-org","type":"organizations"}},
+					"locked-by":{"data":{"id":"user-1","type":"users"}}
 				}
 			}]
 		}`))
 	}))
 	defer mockAPI.Close()
 
-	client, err := tfe.NewClient(&tfe.Config{
+	clientConfig := tfe.Config{
 		Address: mockAPI.URL,
 		Token:   "test",
-	})
+	}
+	client, err := tfe.NewClient(&clientConfig)
 	if err != nil {
 		t.Fatalf("error creating a stub api client: %s", err)
 	}
 
+	policy, err := version.NewConstraint(">= 0.14.3, < 2.0")
+	if err != nil {
+		t.Fatalf("error parsing the version constraint: %s", err)
+	}
+
 	config := &setup.Config{
-		Client: *client,
-		CLI:    setup.CLI{Organizations: []string{"test-org"}},
+		Client:                 *client,
+		ClientConfig:           clientConfig,
+		CLI:                    setup.CLI{Organizations: []string{"test-org"}},
+		TerraformVersionPolicy: policy,
 	}
 
+	// stg was already seen locked by a previous scrape.
+	lockedSince := time.Date(2020, 10, 10, 10, 10, 10, 0, time.UTC)
+	workspaceLocks = newWorkspaceLockCache()
+	defer func() { workspaceLocks = newWorkspaceLockCache() }()
+	workspaceLocks.entries["test-id-2"] = &workspaceLockEntry{locker: "users/user-1", since: lockedSince, seenAt: time.Now()}
+
 	ch := make(chan prometheus.Metric)
 	go func() {
 		defer close(ch)
@@ -86,13 +117,122 @@ func TestScrapeWorkspaces(t *testing.T) {
 	}()
 
 	counterExpected := []MetricResult{
-		{labels: labelMap{"created_at": "1010-10-10 10:10:10.101 +0000 UTC", "current_run": "run-id-1", "current_run_status": "applied", "current_run_created_at": "1010-10-10 10:10:10.101 +0000 UTC", "environment": "test-environment", "id": "test-id-1", "name": "dev", "organization": "test-org", "terraform_version": "0.14.3"}, value: 1, metricType: dto.MetricType_GAUGE},
-		{labels: labelMap{"created_at": "1010-10-10 10:10:10.101 +0000 UTC", "current_run": "na", "current_run_status": "na", "current_run_created_at": "na", "environment": "test-environment", "id": "test-id-2", "name": "stg", "organization": "test-org", "terraform_version": "0.14.2"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"current_run": "run-id-1", "current_run_status": "applied", "environment": "test-environment", "id": "test-id-1", "name": "dev", "organization": "test-org", "terraform_version": "0.14.3"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"id": "test-id-1", "name": "dev", "organization": "test-org"}, value: -30270289789.899, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"id": "test-id-1", "name": "dev", "organization": "test-org"}, value: 1602324610.101, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "version": "0.14.3"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "execution_mode": "agent", "auto_apply": "true", "queue_all_runs": "false", "speculative_enabled": "true", "file_triggers_enabled": "true", "vcs_repo_identifier": "example/infra", "working_directory": "envs/dev", "allow_destroy_plan": "true", "global_remote_state": "false"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"id": "test-id-1", "name": "dev", "organization": "test-org"}, value: -30270289789.899, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "dev", "locked_by_type": ""}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"current_run": "na", "current_run_status": "na", "environment": "test-environment", "id": "test-id-2", "name": "stg", "organization": "test-org", "terraform_version": "0.14.2"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"id": "test-id-2", "name": "stg", "organization": "test-org"}, value: -30270289789.899, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"id": "test-id-2", "name": "stg", "organization": "test-org"}, value: 1602324610.101, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg", "version": "0.14.2"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg", "execution_mode": "local", "auto_apply": "false", "queue_all_runs": "false", "speculative_enabled": "false", "file_triggers_enabled": "false", "vcs_repo_identifier": "na", "working_directory": "na", "allow_destroy_plan": "false", "global_remote_state": "false"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg", "locked_by_type": "user"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "workspace": "stg", "locked_by_type": "user"}, value: 1602324610, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "version": "0.14.2"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"organization": "test-org", "version": "0.14.3"}, value: 1, metricType: dto.MetricType_GAUGE},
 	}
 	convey.Convey("Metrics comparison", t, func() {
 		for _, expect := range counterExpected {
 			got := readMetric(<-ch)
 			convey.So(got, convey.ShouldResemble, expect)
 		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
+
+func TestScrapeWorkspacesPages(t *testing.T) {
+	var mu sync.Mutex
+	requestedPages := map[string]int{}
+	inFlight, maxInFlight := 0, 0
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		organization := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/organizations/"), "/workspaces")
+		if organization == r.URL.Path {
+			return
+		}
+		page := r.URL.Query().Get("page[number]")
+		mu.Lock()
+		requestedPages[organization+"/"+page]++
+		inFlight++
+		maxInFlight = maxInt(maxInFlight, inFlight)
+		mu.Unlock()
+
+		// Keep the request in flight long enough for the others to overlap with it.
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{
+			"meta":{
+				"pagination":{"current-page":%s,"total-pages":3,"total-count":3}
+			},
+			"data":[{
+				"id":"%s-id-%s",
+				"type":"workspaces",
+				"attributes":{"name":"ws-%s"},
+				"relationships":{"organization":{"data":{"id":"%s","type":"organizations"}}}
+			}]
+		}`, page, organization, page, page, organization)))
+	}))
+	defer mockAPI.Close()
+
+	clientConfig := tfe.Config{
+		Address: mockAPI.URL,
+		Token:   "test",
+	}
+	client, err := tfe.NewClient(&clientConfig)
+	if err != nil {
+		t.Fatalf("error creating a stub api client: %s", err)
+	}
+
+	config := &setup.Config{
+		Client:       *client,
+		ClientConfig: clientConfig,
+		CLI:          setup.CLI{Organizations: []string{"test-org", "other-org", "third-org"}, PageSize: 1, PageWorkers: 2},
+	}
+
+	// Two scrapers of the same scrape cycle share the page workers.
+	ctx := withPageWorkers(context.Background(), config.PageWorkers)
+	ch := make(chan prometheus.Metric)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := (ScrapeWorkspaces{}).Scrape(ctx, config, ch); err != nil {
+				t.Errorf("error calling function on test: %s", err)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	ids := []string{}
+	for m := range ch {
+		if m.Desc() == WorkspacesInfo {
+			ids = append(ids, readMetric(m).labels["id"])
+		}
+	}
+	sort.Strings(ids)
+
+	convey.Convey("Every page is requested once per scraper, at most PageWorkers at a time across organizations and scrapers", t, func() {
+		convey.So(ids, convey.ShouldResemble, []string{
+			"other-org-id-1", "other-org-id-1", "other-org-id-2", "other-org-id-2", "other-org-id-3", "other-org-id-3",
+			"test-org-id-1", "test-org-id-1", "test-org-id-2", "test-org-id-2", "test-org-id-3", "test-org-id-3",
+			"third-org-id-1", "third-org-id-1", "third-org-id-2", "third-org-id-2", "third-org-id-3", "third-org-id-3",
+		})
+		convey.So(requestedPages, convey.ShouldResemble, map[string]int{
+			"test-org/1": 2, "test-org/2": 2, "test-org/3": 2,
+			"other-org/1": 2, "other-org/2": 2, "other-org/3": 2,
+			"third-org/1": 2, "third-org/2": 2, "third-org/3": 2,
+		})
+		convey.So(maxInFlight, convey.ShouldBeLessThanOrEqualTo, 2)
 	})
 }
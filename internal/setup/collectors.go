@@ -0,0 +1,76 @@
+package setup
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// Collector describes a scraper that can be enabled or disabled from the CLI.
+type Collector struct {
+	Name    string
+	Help    string
+	Enabled bool
+}
+
+// collectorFlags returns a kong option that adds a --collector.<name> and --no-collector.<name> flag
+// for every collector, and an env var TF_COLLECTOR_<NAME> to set the value of the former.
+func (c *Config) collectorFlags(collectors []Collector) (kong.Option, func()) {
+	registry := kong.NewRegistry().RegisterDefaults()
+	enable := make([]bool, len(collectors))
+	disable := make([]bool, len(collectors))
+
+	newFlag := func(name, help, env string, target *bool, enabled bool) *kong.Flag {
+		value := reflect.ValueOf(target).Elem()
+		flag := &kong.Flag{
+			Value: &kong.Value{
+				Name:         name,
+				Help:         help,
+				Default:      strconv.FormatBool(enabled),
+				DefaultValue: reflect.ValueOf(false),
+				Mapper:       registry.ForValue(value),
+				Tag:          &kong.Tag{Env: env},
+				Target:       value,
+			},
+			Env: env,
+		}
+		flag.Value.Flag = flag
+		return flag
+	}
+
+	option := kong.PostBuild(func(k *kong.Kong) error {
+		for i, collector := range collectors {
+			env := "TF_COLLECTOR_" + strings.ToUpper(strings.Replace(collector.Name, "-", "_", -1))
+			k.Model.Node.Flags = append(k.Model.Node.Flags,
+				newFlag("collector."+collector.Name, collector.Help, env, &enable[i], collector.Enabled),
+				newFlag("no-collector."+collector.Name, "Disable the "+collector.Name+" collector.", "", &disable[i], false),
+			)
+		}
+		return nil
+	})
+
+	// apply copies the parsed flags into the Config once kong is done.
+	apply := func() {
+		c.Collectors = make(map[string]bool, len(collectors))
+		for i, collector := range collectors {
+			c.Collectors[collector.Name] = enable[i] && !disable[i]
+		}
+	}
+
+	return option, apply
+}
+
+// DisabledCollectors returns the names of the collectors turned off.
+func (c *Config) DisabledCollectors() []string {
+	disabled := []string{}
+	for name, enabled := range c.Collectors {
+		if !enabled {
+			disabled = append(disabled, name)
+		}
+	}
+	sort.Strings(disabled)
+	return disabled
+}
@@ -0,0 +1,363 @@
+package setup
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kong"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	version "github.com/hashicorp/go-version"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the structure of the configuration file passed via --config.file, in YAML or HCL.
+// Settings given as flags or env vars take precedence over the ones in the file.
+type File struct {
+	Organizations                []string        `yaml:"organizations" hcl:"organizations,optional"`
+	OrganizationsRefreshInterval string          `yaml:"organizations_refresh_interval" hcl:"organizations_refresh_interval,optional"`
+	APIToken                     string          `yaml:"api_token" hcl:"api_token,optional"`
+	APITokenFile                 string          `yaml:"api_token_file" hcl:"api_token_file,optional"`
+	APITokenID                   string          `yaml:"api_token_id" hcl:"api_token_id,optional"`
+	APIAddress                   string          `yaml:"api_address" hcl:"api_address,optional"`
+	APIInsecureSkipVerify        bool            `yaml:"api_insecure_skip_verify" hcl:"api_insecure_skip_verify,optional"`
+	APICAFile                    string          `yaml:"api_ca_file" hcl:"api_ca_file,optional"`
+	APIRateLimit                 *float64        `yaml:"api_rate_limit" hcl:"api_rate_limit,optional"`
+	APIMaxRetries                *int            `yaml:"api_max_retries" hcl:"api_max_retries,optional"`
+	PageSize                     *int            `yaml:"page_size" hcl:"page_size,optional"`
+	PageWorkers                  *int            `yaml:"page_workers" hcl:"page_workers,optional"`
+	ListenAddress                string          `yaml:"listen_address" hcl:"listen_address,optional"`
+	ScrapeInterval               string          `yaml:"scrape_interval" hcl:"scrape_interval,optional"`
+	LogLevel                     string          `yaml:"log_level" hcl:"log_level,optional"`
+	LogFormat                    string          `yaml:"log_format" hcl:"log_format,optional"`
+	RunsMaxPages                 *int            `yaml:"runs_max_pages" hcl:"runs_max_pages,optional"`
+	TerraformVersionMinimum      string          `yaml:"terraform_version_minimum" hcl:"terraform_version_minimum,optional"`
+	TerraformVersionConstraint   string          `yaml:"terraform_version_constraint" hcl:"terraform_version_constraint,optional"`
+	TeamAccessWorkspaces         string          `yaml:"team_access_workspaces" hcl:"team_access_workspaces,optional"`
+	VariablesSecretKeys          string          `yaml:"variables_secret_keys" hcl:"variables_secret_keys,optional"`
+	StateVersionsDownload        bool            `yaml:"state_versions_download" hcl:"state_versions_download,optional"`
+	CompatTimestampLabels        bool            `yaml:"compat_timestamp_labels" hcl:"compat_timestamp_labels,optional"`
+	Collectors                   map[string]bool `yaml:"collectors" hcl:"collectors,optional"`
+	Targets                      []Target        `yaml:"targets" hcl:"target,block"`
+
+	// positions maps the path of each setting (e.g. "targets.0.api_token") to the file:line defining it.
+	positions map[string]string
+	path      string
+}
+
+// loadFile parses the configuration file at path, choosing the format from its extension.
+func loadFile(path string) (*File, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{positions: map[string]string{}, path: path}
+	switch filepath.Ext(path) {
+	case ".yml", ".yaml":
+		err = f.decodeYAML(b)
+	case ".hcl":
+		err = f.decodeHCL(b)
+	default:
+		err = fmt.Errorf("%s: unsupported config file format, use .yml, .yaml or .hcl", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *File) decodeYAML(b []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(f); err != nil {
+		return fmt.Errorf("%s: %v", f.path, err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return fmt.Errorf("%s: %v", f.path, err)
+	}
+	if len(root.Content) > 0 {
+		f.recordYAML("", root.Content[0])
+	}
+
+	return nil
+}
+
+// recordYAML walks the YAML document and records the line of every key.
+func (f *File) recordYAML(prefix string, node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := prefix + node.Content[i].Value
+			f.positions[key] = fmt.Sprintf("%s:%d", f.path, node.Content[i].Line)
+			f.recordYAML(key+".", node.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			key := fmt.Sprintf("%s%d", prefix, i)
+			f.positions[key] = fmt.Sprintf("%s:%d", f.path, item.Line)
+			f.recordYAML(key+".", item)
+		}
+	}
+}
+
+func (f *File) decodeHCL(b []byte) error {
+	file, diags := hclsyntax.ParseConfig(b, f.path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return diags
+	}
+	if diags := gohcl.DecodeBody(file.Body, nil, f); diags.HasErrors() {
+		return diags
+	}
+
+	body := file.Body.(*hclsyntax.Body)
+	for name, attr := range body.Attributes {
+		f.positions[name] = fmt.Sprintf("%s:%d", f.path, attr.SrcRange.Start.Line)
+	}
+	for i, block := range body.Blocks {
+		key := fmt.Sprintf("targets.%d", i)
+		f.positions[key] = fmt.Sprintf("%s:%d", f.path, block.DefRange().Start.Line)
+		for name, attr := range block.Body.Attributes {
+			f.positions[key+"."+name] = fmt.Sprintf("%s:%d", f.path, attr.SrcRange.Start.Line)
+		}
+	}
+
+	return nil
+}
+
+// position returns the file:line defining key, or the closest parent setting found in the file.
+func (f *File) position(key string) string {
+	for {
+		if p, ok := f.positions[key]; ok {
+			return p
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return f.path
+		}
+		key = key[:i]
+	}
+}
+
+// validate returns an error listing every problem found in the file, each with its file:line.
+func (f *File) validate(collectors []Collector) error {
+	problems := []string{}
+	problem := func(key, format string, args ...interface{}) {
+		problems = append(problems, f.position(key)+": "+fmt.Sprintf(format, args...))
+	}
+
+	if f.APIToken != "" && f.APITokenFile != "" {
+		problem("api_token_file", "only one of api_token and api_token_file can be set")
+	}
+	if f.APIRateLimit != nil && *f.APIRateLimit < 0 {
+		problem("api_rate_limit", "api_rate_limit must not be negative but got %g", *f.APIRateLimit)
+	}
+	if f.APIMaxRetries != nil && *f.APIMaxRetries < 0 {
+		problem("api_max_retries", "api_max_retries must not be negative but got %d", *f.APIMaxRetries)
+	}
+	if f.PageSize != nil && (*f.PageSize < 1 || *f.PageSize > 100) {
+		problem("page_size", "page_size must be between 1 and 100 but got %d", *f.PageSize)
+	}
+	if f.PageWorkers != nil && *f.PageWorkers < 1 {
+		problem("page_workers", "page_workers must be at least 1 but got %d", *f.PageWorkers)
+	}
+	if f.RunsMaxPages != nil && *f.RunsMaxPages < 1 {
+		problem("runs_max_pages", "runs_max_pages must be at least 1 but got %d", *f.RunsMaxPages)
+	}
+	if f.OrganizationsRefreshInterval != "" {
+		if d, err := time.ParseDuration(f.OrganizationsRefreshInterval); err != nil || d < 0 {
+			problem("organizations_refresh_interval", "invalid duration %q", f.OrganizationsRefreshInterval)
+		}
+	}
+	if f.ScrapeInterval != "" {
+		if d, err := time.ParseDuration(f.ScrapeInterval); err != nil || d < 0 {
+			problem("scrape_interval", "invalid duration %q", f.ScrapeInterval)
+		}
+	}
+	if f.TerraformVersionMinimum != "" {
+		if _, err := version.NewVersion(f.TerraformVersionMinimum); err != nil {
+			problem("terraform_version_minimum", "invalid version %q", f.TerraformVersionMinimum)
+		}
+	}
+	if f.TerraformVersionConstraint != "" {
+		if _, err := version.NewConstraint(f.TerraformVersionConstraint); err != nil {
+			problem("terraform_version_constraint", "invalid constraint %q", f.TerraformVersionConstraint)
+		}
+	}
+	if f.TeamAccessWorkspaces != "" {
+		if _, err := regexp.Compile(f.TeamAccessWorkspaces); err != nil {
+			problem("team_access_workspaces", "invalid regular expression %q", f.TeamAccessWorkspaces)
+		}
+	}
+	if f.VariablesSecretKeys != "" {
+		if _, err := regexp.Compile(f.VariablesSecretKeys); err != nil {
+			problem("variables_secret_keys", "invalid regular expression %q", f.VariablesSecretKeys)
+		}
+	}
+	if !oneOf(f.LogLevel, "", "debug", "info", "warn", "error") {
+		problem("log_level", "log_level must be one of debug,info,warn,error but got %q", f.LogLevel)
+	}
+	if !oneOf(f.LogFormat, "", "logfmt", "json") {
+		problem("log_format", "log_format must be one of logfmt,json but got %q", f.LogFormat)
+	}
+
+	known := map[string]bool{}
+	for _, collector := range collectors {
+		known[collector.Name] = true
+	}
+	for name := range f.Collectors {
+		if !known[name] {
+			problem("collectors."+name, "unknown collector %q", name)
+		}
+	}
+
+	names := map[string]bool{}
+	for i, target := range f.Targets {
+		key := fmt.Sprintf("targets.%d", i)
+		switch {
+		case target.Name == "":
+			problem(key, "target name is required")
+		case names[target.Name]:
+			problem(key, "duplicate target %q", target.Name)
+		}
+		names[target.Name] = true
+
+		if (target.APIToken == "") == (target.APITokenFile == "") {
+			problem(key, "exactly one of api_token and api_token_file must be set for target %q", target.Name)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config file:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// apply copies the settings from the file into the Config, unless they were set via flags or env vars.
+func (f *File) apply(ctx *kong.Context, c *Config) error {
+	if len(f.Organizations) > 0 && !flagSet(ctx, "organizations") {
+		c.Organizations = f.Organizations
+	}
+	if f.OrganizationsRefreshInterval != "" && !flagSet(ctx, "organizations-refresh-interval") {
+		c.OrganizationsRefreshInterval, _ = time.ParseDuration(f.OrganizationsRefreshInterval)
+	}
+	if !flagSet(ctx, "api-token") && !flagSet(ctx, "api-token-file") {
+		if f.APIToken != "" {
+			c.APIToken = f.APIToken
+		}
+		if f.APITokenFile != "" {
+			tokenFile, err := os.Open(f.APITokenFile)
+			if err != nil {
+				return fmt.Errorf("%s: %v", f.position("api_token_file"), err)
+			}
+			c.APITokenFile = tokenFile
+		}
+	}
+	if f.APITokenID != "" && !flagSet(ctx, "api-token-id") {
+		c.APITokenID = f.APITokenID
+	}
+	if f.APIAddress != "" && !flagSet(ctx, "api-address") {
+		c.APIAddress = f.APIAddress
+	}
+	if f.APIInsecureSkipVerify && !flagSet(ctx, "api-insecure-skip-verify") {
+		c.APIInsecureSkipVerify = f.APIInsecureSkipVerify
+	}
+	if f.APICAFile != "" && !flagSet(ctx, "api-ca-file") {
+		c.APICAFile = f.APICAFile
+	}
+	if f.APIRateLimit != nil && !flagSet(ctx, "api-rate-limit") {
+		c.APIRateLimit = *f.APIRateLimit
+	}
+	if f.APIMaxRetries != nil && !flagSet(ctx, "api-max-retries") {
+		c.APIMaxRetries = *f.APIMaxRetries
+	}
+	if f.PageSize != nil && !flagSet(ctx, "page-size") {
+		c.PageSize = *f.PageSize
+	}
+	if f.PageWorkers != nil && !flagSet(ctx, "page-workers") {
+		c.PageWorkers = *f.PageWorkers
+	}
+	if f.ListenAddress != "" && !flagSet(ctx, "listen-address") {
+		c.ListenAddress = f.ListenAddress
+	}
+	if f.ScrapeInterval != "" && !flagSet(ctx, "scrape-interval") {
+		c.ScrapeInterval, _ = time.ParseDuration(f.ScrapeInterval)
+	}
+	if f.LogLevel != "" && !flagSet(ctx, "log-level") {
+		c.LogLevel = f.LogLevel
+	}
+	if f.LogFormat != "" && !flagSet(ctx, "log-format") {
+		c.LogFormat = f.LogFormat
+	}
+	if f.RunsMaxPages != nil && !flagSet(ctx, "runs.max-pages") {
+		c.RunsMaxPages = *f.RunsMaxPages
+	}
+	if f.TerraformVersionMinimum != "" && !flagSet(ctx, "terraform-version.minimum") {
+		c.TerraformVersionMinimum = f.TerraformVersionMinimum
+	}
+	if f.TerraformVersionConstraint != "" && !flagSet(ctx, "terraform-version.constraint") {
+		c.TerraformVersionConstraint = f.TerraformVersionConstraint
+	}
+	if f.TeamAccessWorkspaces != "" && !flagSet(ctx, "team-access.workspaces") {
+		c.TeamAccessWorkspaces = f.TeamAccessWorkspaces
+	}
+	if f.VariablesSecretKeys != "" && !flagSet(ctx, "variables.secret-keys") {
+		c.VariablesSecretKeys = f.VariablesSecretKeys
+	}
+	if f.StateVersionsDownload && !flagSet(ctx, "state-versions.download") {
+		c.StateVersionsDownload = f.StateVersionsDownload
+	}
+	if f.CompatTimestampLabels && !flagSet(ctx, "compat.timestamp-labels") {
+		c.CompatTimestampLabels = f.CompatTimestampLabels
+	}
+	for name, enabled := range f.Collectors {
+		if !flagSet(ctx, "collector."+name) && !flagSet(ctx, "no-collector."+name) {
+			c.Collectors[name] = enabled
+		}
+	}
+
+	c.Targets = make(map[string]Target, len(f.Targets))
+	for _, target := range f.Targets {
+		c.Targets[target.Name] = target
+	}
+
+	return nil
+}
+
+// flagSet returns whether the named flag was given on the command line or via its env var.
+func flagSet(ctx *kong.Context, name string) bool {
+	for _, trace := range ctx.Path {
+		if trace.Flag != nil && trace.Flag.Name == name {
+			return true
+		}
+	}
+	for _, flag := range ctx.Flags() {
+		if flag.Name == name && flag.Env != "" {
+			_, ok := os.LookupEnv(flag.Env)
+			return ok
+		}
+	}
+	return false
+}
+
+func oneOf(value string, options ...string) bool {
+	for _, option := range options {
+		if value == option {
+			return true
+		}
+	}
+	return false
+}
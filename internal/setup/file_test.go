@@ -0,0 +1,153 @@
+package setup
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+var testCollectors = []Collector{
+	{Name: "runs", Enabled: false},
+	{Name: "workspaces", Enabled: true},
+}
+
+const testYAML = `organizations: [org-a, org-b]
+page_size: 50
+page_workers: 2
+runs_max_pages: 3
+collectors:
+  runs: true
+targets:
+  - name: tfe-prod
+    api_address: https://tfe.example.com
+    api_token: secret
+`
+
+const testHCL = `organizations  = ["org-a", "org-b"]
+page_size      = 50
+page_workers   = 2
+runs_max_pages = 3
+collectors = {
+  runs = true
+}
+
+target "tfe-prod" {
+  api_address = "https://tfe.example.com"
+  api_token   = "secret"
+}
+`
+
+// writeConfigFile writes content to a file named name in a temporary directory and returns its path.
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("error writing the config file: %s", err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	convey.Convey("Config files", t, func() {
+		for name, content := range map[string]string{"config.yml": testYAML, "config.hcl": testHCL} {
+			path := writeConfigFile(t, name, content)
+
+			convey.Convey("are parsed from "+name, func() {
+				f, err := loadFile(path)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(f.validate(testCollectors), convey.ShouldBeNil)
+				convey.So(f.Organizations, convey.ShouldResemble, []string{"org-a", "org-b"})
+				convey.So(*f.PageSize, convey.ShouldEqual, 50)
+				convey.So(*f.PageWorkers, convey.ShouldEqual, 2)
+				convey.So(*f.RunsMaxPages, convey.ShouldEqual, 3)
+				convey.So(f.Collectors, convey.ShouldResemble, map[string]bool{"runs": true})
+				convey.So(f.Targets, convey.ShouldResemble, []Target{{Name: "tfe-prod", APIAddress: "https://tfe.example.com", APIToken: "secret"}})
+			})
+		}
+
+		convey.Convey("reject unknown keys", func() {
+			_, err := loadFile(writeConfigFile(t, "config.yml", "organisations: [org-a]\n"))
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "organisations")
+
+			_, err = loadFile(writeConfigFile(t, "config.hcl", "organisations = [\"org-a\"]\n"))
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "organisations")
+		})
+
+		convey.Convey("reject unsupported formats", func() {
+			_, err := loadFile(writeConfigFile(t, "config.json", "{}"))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("report problems with their file and line", func() {
+			path := writeConfigFile(t, "config.yml", "page_size: 50\npage_workers: 0\nruns_max_pages: 0\ncollectors:\n  bogus: true\n")
+			f, err := loadFile(path)
+			convey.So(err, convey.ShouldBeNil)
+
+			err = f.validate(testCollectors)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, path+":2: page_workers must be at least 1 but got 0")
+			convey.So(err.Error(), convey.ShouldContainSubstring, path+":3: runs_max_pages must be at least 1 but got 0")
+			convey.So(err.Error(), convey.ShouldContainSubstring, path+":5: unknown collector \"bogus\"")
+
+			path = writeConfigFile(t, "config.hcl", "page_size = 50\n\ntarget \"tfe-prod\" {\n  api_address = \"https://tfe.example.com\"\n}\n")
+			f, err = loadFile(path)
+			convey.So(err, convey.ShouldBeNil)
+
+			err = f.validate(testCollectors)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, path+":3: exactly one of api_token and api_token_file must be set")
+		})
+	})
+}
+
+func TestParseConfig(t *testing.T) {
+	path := writeConfigFile(t, "config.yml", testYAML)
+
+	convey.Convey("Parsing the CLI", t, func() {
+		newTestConfig := func() *Config {
+			return &Config{targetConfigs: &targetConfigs{configs: map[string]*targetConfig{}}}
+		}
+
+		convey.Convey("applies the config file", func() {
+			c := newTestConfig()
+			_, err := c.parse([]string{"--config.file=" + path}, testCollectors)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(c.Organizations, convey.ShouldResemble, []string{"org-a", "org-b"})
+			convey.So(c.PageSize, convey.ShouldEqual, 50)
+			convey.So(c.RunsMaxPages, convey.ShouldEqual, 3)
+			convey.So(c.Collectors, convey.ShouldResemble, map[string]bool{"runs": true, "workspaces": true})
+			convey.So(c.Targets, convey.ShouldContainKey, "tfe-prod")
+		})
+
+		convey.Convey("gives precedence to flags over the config file", func() {
+			c := newTestConfig()
+			_, err := c.parse([]string{"--config.file=" + path, "--page-size=10", "--organizations=org-c", "--no-collector.runs"}, testCollectors)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(c.PageSize, convey.ShouldEqual, 10)
+			convey.So(c.Organizations, convey.ShouldResemble, []string{"org-c"})
+			convey.So(c.Collectors["runs"], convey.ShouldBeFalse)
+			convey.So(c.PageWorkers, convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("validates the config file with --config.check", func() {
+			c := newTestConfig()
+			_, err := c.parse([]string{"--config.file=" + path, "--config.check"}, testCollectors)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(c.ConfigCheck, convey.ShouldBeTrue)
+
+			invalid := writeConfigFile(t, "invalid.yml", "page_size: 500\n")
+			_, err = newTestConfig().parse([]string{"--config.file=" + invalid, "--config.check"}, testCollectors)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, invalid+":1: page_size must be between 1 and 100 but got 500")
+		})
+
+		convey.Convey("requires --config.file with --config.check", func() {
+			_, err := newTestConfig().parse([]string{"--config.check"}, testCollectors)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "--config.check requires --config.file")
+		})
+	})
+}
@@ -3,8 +3,14 @@ package setup
 import (
 	"bufio"
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -13,34 +19,166 @@ import (
 	"github.com/alecthomas/kong"
 
 	tfe "github.com/hashicorp/go-tfe"
+	version "github.com/hashicorp/go-version"
 )
 
 type CLI struct {
-	Organizations         []string `short:"o" env:"TF_ORGANIZATIONS" placeholder:"ORG1,ORG2" help:"List of the Organization names to scrape from (Ommit to scrape all)."`
-	APIToken              string   `short:"t" env:"TF_API_TOKEN" help:"User token for autheticating with the API."`
-	APITokenFile          *os.File `placeholder:"/path/to/file" help:"File containing user token for autheticating with the API."`
-	APIAddress            string   `placeholder:"https://app.terraform.io/" help:"Terraform API address to scrape metrics from."`
-	APIInsecureSkipVerify bool     `help:"Accept any certificate presented by the API."`
-	ListenAddress         string   `default:"0.0.0.0:9100" help:"Address to listen on for web interface and telemetry."`
-	LogLevel              string   `default:"info" enum:"debug,info,warn,error" help:"Only log messages with the given severity or above. One of: [${enum}]"`
-	LogFormat             string   `default:"logfmt" enum:"logfmt,json" help:"Output format of log messages. One of: [${enum}]"`
+	Organizations                []string      `short:"o" env:"TF_ORGANIZATIONS" placeholder:"ORG1,ORG2" help:"List of the Organization names to scrape from (Ommit to scrape all)."`
+	OrganizationsRefreshInterval time.Duration `default:"5m" help:"How often to list the organizations visible to the token again, when none are given."`
+	APIToken                     string        `short:"t" env:"TF_API_TOKEN" help:"User token for autheticating with the API."`
+	APITokenFile                 *os.File      `placeholder:"/path/to/file" help:"File containing user token for autheticating with the API."`
+	APITokenID                   string        `placeholder:"at-XXXX" help:"ID of the API token, so that the tokens collector can export its expiry."`
+	APIAddress                   string        `placeholder:"https://app.terraform.io/" help:"Terraform API address to scrape metrics from."`
+	APIInsecureSkipVerify        bool          `help:"Accept any certificate presented by the API."`
+	APICAFile                    string        `name:"api-ca-file" placeholder:"/path/to/ca.pem" help:"PEM file with the certificate authorities to trust, on top of the system ones, when verifying the API certificate."`
+	APIRateLimit                 float64       `default:"20" help:"Maximum number of requests per second sent to each API (0 disables the limit)."`
+	APIMaxRetries                int           `default:"5" help:"Maximum number of times a request rejected with 429 Too Many Requests is retried."`
+	PageSize                     int           `default:"20" help:"Number of items requested per page from paginated APIs (max 100), larger pages mean fewer but slower requests."`
+	PageWorkers                  int           `default:"4" help:"Maximum number of pages of workspaces fetched concurrently, across all organizations and collectors."`
+	ListenAddress                string        `default:"0.0.0.0:9100" help:"Address to listen on for web interface and telemetry."`
+	ScrapeInterval               time.Duration `default:"0s" help:"Scrape the API in the background on this interval and serve the last successful result (0 scrapes on every request)."`
+	LogLevel                     string        `default:"info" enum:"debug,info,warn,error" help:"Only log messages with the given severity or above. One of: [${enum}]"`
+	LogFormat                    string        `default:"logfmt" enum:"logfmt,json" help:"Output format of log messages. One of: [${enum}]"`
+	RunsMaxPages                 int           `name:"runs.max-pages" default:"1" help:"Number of pages of the most recent runs of each workspace read on every scrape by the runs collector."`
+	TerraformVersionMinimum      string        `name:"terraform-version.minimum" placeholder:"0.13.0" help:"Oldest Terraform version workspaces may use to be reported as compliant."`
+	TerraformVersionConstraint   string        `name:"terraform-version.constraint" placeholder:"\">= 0.13, < 2.0\"" help:"Versions workspaces may use to be reported as compliant, in the syntax of Terraform's required_version."`
+	TeamAccessWorkspaces         string        `name:"team-access.workspaces" placeholder:"REGEX" help:"Only audit the team access of the workspaces whose name matches this regular expression, used by the team_access collector."`
+	VariablesSecretKeys          string        `name:"variables.secret-keys" default:"(?i)(secret|passw(or)?d|token|api_?key|private_?key|credential)" placeholder:"REGEX" help:"Keys of non-sensitive variables matching this regular expression are reported as suspected secrets by the variables collector."`
+	StateVersionsDownload        bool          `name:"state-versions.download" help:"Download the current state of workspaces whose resources or size the API does not report, used by the state_versions collector."`
+	CompatTimestampLabels        bool          `name:"compat.timestamp-labels" help:"Deprecated: keep the created_at and current_run_created_at labels on the info metrics, they will be removed in the next release."`
+	ConfigFile                   string        `name:"config.file" env:"TF_CONFIG_FILE" placeholder:"/path/to/config.yml" help:"YAML (.yml/.yaml) or HCL (.hcl) file with settings and /probe targets, flags and env vars take precedence."`
+	ConfigCheck                  bool          `name:"config.check" help:"Parse and validate the config file, then exit."`
 }
 
 type Config struct {
 	CLI
 	Client tfe.Client
-	Logger log.Logger
+	// ClientConfig holds the address, token and HTTP client the tfe.Client was built with,
+	// so that API endpoints not covered by go-tfe can be reached with the same settings.
+	ClientConfig tfe.Config
+	// Collectors maps the name of every known collector to whether it is enabled.
+	Collectors map[string]bool
+	// TerraformVersionPolicy combines the minimum and the constraint workspaces must satisfy to be compliant,
+	// it is empty when neither is set.
+	TerraformVersionPolicy version.Constraints
+	// TeamAccessWorkspacesPattern is the compiled --team-access.workspaces, nil when every workspace is audited.
+	TeamAccessWorkspacesPattern *regexp.Regexp
+	// VariablesSecretKeysPattern is the compiled --variables.secret-keys, nil when it is empty.
+	VariablesSecretKeysPattern *regexp.Regexp
+	// Targets maps the names accepted by the /probe endpoint to the API they scrape.
+	Targets       map[string]Target
+	targetConfigs *targetConfigs
+	Logger        log.Logger
 }
 
 // NewConfig returns a new Config object that was initialized according to the CLI params.
-func NewConfig() Config {
-	config := Config{}
-	kong.Parse(&config.CLI)
+// A pair of enable/disable flags is generated for each of the given collectors.
+func NewConfig(collectors []Collector) Config {
+	config := Config{targetConfigs: &targetConfigs{configs: map[string]*targetConfig{}}}
+	parser, err := config.parse(os.Args[1:], collectors)
+	if parser == nil {
+		panic(err)
+	}
+	parser.FatalIfErrorf(err)
+	if config.ConfigCheck {
+		fmt.Printf("%s: OK\n", config.ConfigFile)
+		os.Exit(0)
+	}
 	config.setupLogger()
 	config.setupClient()
+	if disabled := config.DisabledCollectors(); len(disabled) > 0 {
+		level.Info(config.Logger).Log("msg", "Disabled collectors", "collectors", strings.Join(disabled, ","))
+	}
 	return config
 }
 
+// parse parses args into the Config, merges the config file and validates the result.
+// The parser is nil when the CLI model itself is invalid.
+func (c *Config) parse(args []string, collectors []Collector, options ...kong.Option) (*kong.Kong, error) {
+	collectorFlags, applyCollectorFlags := c.collectorFlags(collectors)
+	parser, err := kong.New(&c.CLI, append(options, collectorFlags)...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := parser.Parse(args)
+	if err != nil {
+		return parser, err
+	}
+	applyCollectorFlags()
+	if err := c.loadConfigFile(ctx, collectors); err != nil {
+		return parser, err
+	}
+	return parser, c.validate()
+}
+
+func (c *Config) loadConfigFile(ctx *kong.Context, collectors []Collector) error {
+	if c.ConfigFile == "" {
+		if c.ConfigCheck {
+			return fmt.Errorf("--config.check requires --config.file")
+		}
+		return nil
+	}
+
+	f, err := loadFile(c.ConfigFile)
+	if err != nil {
+		return err
+	}
+	if err := f.validate(collectors); err != nil {
+		return err
+	}
+	return f.apply(ctx, c)
+}
+
+// validate checks the settings kong can not validate by itself, once flags and config file are merged.
+func (c *Config) validate() error {
+	if c.PageSize < 1 || c.PageSize > 100 {
+		return fmt.Errorf("--page-size must be between 1 and 100 but got %d", c.PageSize)
+	}
+	if c.PageWorkers < 1 {
+		return fmt.Errorf("--page-workers must be at least 1 but got %d", c.PageWorkers)
+	}
+	if c.RunsMaxPages < 1 {
+		return fmt.Errorf("--runs.max-pages must be at least 1 but got %d", c.RunsMaxPages)
+	}
+	if c.APIRateLimit < 0 {
+		return fmt.Errorf("--api-rate-limit must not be negative but got %g", c.APIRateLimit)
+	}
+	if c.APIMaxRetries < 0 {
+		return fmt.Errorf("--api-max-retries must not be negative but got %d", c.APIMaxRetries)
+	}
+	if c.TerraformVersionMinimum != "" {
+		minimum, err := version.NewVersion(c.TerraformVersionMinimum)
+		if err != nil {
+			return fmt.Errorf("invalid --terraform-version.minimum: %v", err)
+		}
+		constraints, _ := version.NewConstraint(">= " + minimum.String())
+		c.TerraformVersionPolicy = append(c.TerraformVersionPolicy, constraints...)
+	}
+	if c.TerraformVersionConstraint != "" {
+		constraints, err := version.NewConstraint(c.TerraformVersionConstraint)
+		if err != nil {
+			return fmt.Errorf("invalid --terraform-version.constraint: %v", err)
+		}
+		c.TerraformVersionPolicy = append(c.TerraformVersionPolicy, constraints...)
+	}
+	if c.TeamAccessWorkspaces != "" {
+		pattern, err := regexp.Compile(c.TeamAccessWorkspaces)
+		if err != nil {
+			return fmt.Errorf("invalid --team-access.workspaces: %v", err)
+		}
+		c.TeamAccessWorkspacesPattern = pattern
+	}
+	if c.VariablesSecretKeys != "" {
+		pattern, err := regexp.Compile(c.VariablesSecretKeys)
+		if err != nil {
+			return fmt.Errorf("invalid --variables.secret-keys: %v", err)
+		}
+		c.VariablesSecretKeysPattern = pattern
+	}
+	return nil
+}
+
 func (c *Config) setupLogger() {
 	// Changes timestamp from 9 variable to 3 fixed
 	// decimals (.130 instead of .130987456).
@@ -70,38 +208,89 @@ func (c *Config) setupLogger() {
 }
 
 func (c *Config) setupClient() {
-	config := &tfe.Config{}
-
+	var token string
 	if c.APITokenFile != nil {
 		defer c.APITokenFile.Close()
-		scanner := bufio.NewScanner(c.APITokenFile)
-		scanner.Scan()
-		config.Token = scanner.Text()
+		token = readToken(c.APITokenFile)
 	} else if c.APIToken != "" {
-		config.Token = c.APIToken
+		token = c.APIToken
 	} else {
 		level.Error(c.Logger).Log("msg", "Error creating tfe client", "err", "Missing API Token.")
 		os.Exit(1)
 	}
 
 	if c.APIAddress != "" {
-		config.Address = c.APIAddress
 		level.Info(c.Logger).Log("msg", "Overwritten Terraform API address", "address", c.APIAddress)
 	}
 
 	if c.APIInsecureSkipVerify {
-		config.HTTPClient = &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: c.APIInsecureSkipVerify},
-			},
-		}
 		level.Warn(c.Logger).Log("msg", "HTTP InsecureSkipVerify is enabled.")
 	}
 
-	client, err := tfe.NewClient(config)
+	client, config, err := c.newClient("", c.APIAddress, token, c.APIInsecureSkipVerify, c.APICAFile)
 	if err != nil {
 		level.Error(c.Logger).Log("msg", "Error creating tfe client", "err", err)
 		os.Exit(1)
 	}
 	c.Client = *client
+	c.ClientConfig = *config
+}
+
+// newClient creates a tfe.Client for the API at address (the Terraform Cloud API when empty).
+// Every client gets its own rate limiter, so that targets do not slow each other down,
+// target labels the API request metrics and is empty for the default API.
+// The certificate authorities in caFile, when set, are trusted on top of the system ones.
+func (c *Config) newClient(target, address, token string, insecureSkipVerify bool, caFile string) (*tfe.Client, *tfe.Config, error) {
+	config := &tfe.Config{
+		Address:  tfe.DefaultAddress,
+		BasePath: tfe.DefaultBasePath,
+		Token:    token,
+	}
+
+	if address != "" {
+		config.Address = address
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if insecureSkipVerify || caFile != "" {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	}
+	if caFile != "" {
+		pool, err := loadCAFile(caFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	config.HTTPClient = &http.Client{Transport: newRateLimitTransport(transport, target, c.APIRateLimit, c.APIMaxRetries)}
+
+	client, err := tfe.NewClient(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, config, nil
+}
+
+// loadCAFile returns the system certificate pool with the PEM certificates of path added.
+func loadCAFile(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificate found in %s", path)
+	}
+	return pool, nil
+}
+
+// readToken returns the first line of r.
+func readToken(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	scanner.Scan()
+	return scanner.Text()
 }
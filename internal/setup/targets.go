@@ -0,0 +1,85 @@
+package setup
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+// Target describes a Terraform Cloud/Enterprise API that can be scraped through the /probe endpoint.
+type Target struct {
+	Name                  string   `yaml:"name" hcl:"name,label"`
+	APIAddress            string   `yaml:"api_address" hcl:"api_address,optional"`
+	APIToken              string   `yaml:"api_token" hcl:"api_token,optional"`
+	APITokenFile          string   `yaml:"api_token_file" hcl:"api_token_file,optional"`
+	APITokenID            string   `yaml:"api_token_id" hcl:"api_token_id,optional"`
+	APIInsecureSkipVerify bool     `yaml:"api_insecure_skip_verify" hcl:"api_insecure_skip_verify,optional"`
+	APICAFile             string   `yaml:"api_ca_file" hcl:"api_ca_file,optional"`
+	Organizations         []string `yaml:"organizations" hcl:"organizations,optional"`
+}
+
+// targetConfigs caches one Config (and so one tfe.Client) per target.
+type targetConfigs struct {
+	mu      sync.Mutex
+	configs map[string]*targetConfig
+}
+
+// targetConfig guards the creation of the Config of one target, which pings its API,
+// so that a slow target does not hold back the /probe requests of the others.
+type targetConfig struct {
+	mu     sync.Mutex
+	config *Config
+}
+
+// Target returns a copy of the Config that scrapes the named target instead of the default API.
+// The tfe.Client of every target is created on first use and reused afterwards.
+func (c Config) Target(name string) (Config, error) {
+	target, ok := c.Targets[name]
+	if !ok {
+		return Config{}, fmt.Errorf("unknown target %q", name)
+	}
+
+	c.targetConfigs.mu.Lock()
+	tc, ok := c.targetConfigs.configs[name]
+	if !ok {
+		tc = &targetConfig{}
+		c.targetConfigs.configs[name] = tc
+	}
+	c.targetConfigs.mu.Unlock()
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.config != nil {
+		return *tc.config, nil
+	}
+
+	token := target.APIToken
+	if target.APITokenFile != "" {
+		f, err := os.Open(target.APITokenFile)
+		if err != nil {
+			return Config{}, fmt.Errorf("%v, target=%s", err, name)
+		}
+		defer f.Close()
+		token = readToken(f)
+	}
+	if token == "" {
+		return Config{}, fmt.Errorf("missing API token, target=%s", name)
+	}
+
+	client, clientConfig, err := c.newClient(name, target.APIAddress, token, target.APIInsecureSkipVerify, target.APICAFile)
+	if err != nil {
+		return Config{}, fmt.Errorf("%v, target=%s", err, name)
+	}
+
+	config := c
+	config.Client = *client
+	config.ClientConfig = *clientConfig
+	config.APITokenID = target.APITokenID
+	config.Organizations = target.Organizations
+	config.Logger = log.With(c.Logger, "target", name)
+	tc.config = &config
+
+	return config, nil
+}
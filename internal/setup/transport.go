@@ -0,0 +1,166 @@
+package setup
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	retryBackoffMin = 500 * time.Millisecond
+	retryBackoffMax = 30 * time.Second
+)
+
+// Metrics about the requests sent to the Terraform API, shared by every target.
+var (
+	APIRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "tf",
+			Subsystem: "exporter",
+			Name:      "api_requests_total",
+			Help:      "Total number of requests sent to the Terraform API, by target, endpoint and HTTP status code.",
+		},
+		[]string{"target", "endpoint", "code"},
+	)
+	APIRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "tf",
+			Subsystem: "exporter",
+			Name:      "api_request_duration_seconds",
+			Help:      "Duration of the requests sent to the Terraform API, by target and endpoint.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"target", "endpoint"},
+	)
+	APIRateLimited = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "tf",
+			Subsystem: "exporter",
+			Name:      "api_rate_limited_total",
+			Help:      "Total number of requests rejected by the Terraform API with 429 Too Many Requests, by target.",
+		},
+		[]string{"target"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(APIRequestsTotal, APIRequestDuration, APIRateLimited)
+}
+
+// rateLimitTransport throttles the requests sent to the API with a token bucket and retries the
+// ones rejected with 429 Too Many Requests, waiting as long as the API asks to.
+// Once the retries are exhausted it returns an error rather than the 429 response, so that the
+// retrying HTTP client of go-tfe, which would retry 429 responses again, gives up too.
+type rateLimitTransport struct {
+	next       http.RoundTripper
+	target     string
+	limiter    *rate.Limiter
+	maxRetries int
+}
+
+// newRateLimitTransport returns a rateLimitTransport labelling its metrics with target, empty for the default API.
+func newRateLimitTransport(next http.RoundTripper, target string, requestsPerSecond float64, maxRetries int) *rateLimitTransport {
+	limit, burst := rate.Inf, 1
+	if requestsPerSecond > 0 {
+		limit = rate.Limit(requestsPerSecond)
+		burst = int(math.Max(1, requestsPerSecond))
+	}
+
+	return &rateLimitTransport{
+		next:       next,
+		target:     target,
+		limiter:    rate.NewLimiter(limit, burst),
+		maxRetries: maxRetries,
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointName(req.URL.Path)
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		resp, err := t.next.RoundTrip(req)
+		APIRequestDuration.WithLabelValues(t.target, endpoint).Observe(time.Since(start).Seconds())
+		if err != nil {
+			APIRequestsTotal.WithLabelValues(t.target, endpoint, "error").Inc()
+			return nil, err
+		}
+		APIRequestsTotal.WithLabelValues(t.target, endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+		APIRateLimited.WithLabelValues(t.target).Inc()
+		resp.Body.Close()
+
+		// Requests with a body can only be sent again if it can be rewound.
+		if attempt >= t.maxRetries || (req.Body != nil && req.GetBody == nil) {
+			return nil, fmt.Errorf("rate limited by the API, gave up after %d retries: %s %s", attempt, req.Method, endpoint)
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		timer := time.NewTimer(retryBackoff(attempt, resp))
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryBackoff returns how long to wait before retrying a rate limited request: the delay given by the
+// Retry-After or X-RateLimit-Reset headers when present, an exponential backoff otherwise.
+func retryBackoff(attempt int, resp *http.Response) time.Duration {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if date, err := http.ParseTime(v); err == nil {
+			if d := time.Until(date); d > 0 {
+				return d
+			}
+			return 0
+		}
+	}
+	if v := resp.Header.Get("X-RateLimit-Reset"); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+	}
+
+	backoff := retryBackoffMin << uint(attempt)
+	if backoff <= 0 || backoff > retryBackoffMax {
+		return retryBackoffMax
+	}
+	return backoff
+}
+
+// endpointName turns a request path into a label with bounded cardinality by replacing the IDs and
+// names in it, e.g. /api/v2/workspaces/ws-123/runs becomes workspaces/:id/runs.
+// Requests outside of the API, e.g. state downloads from signed URLs, are all labelled "download".
+func endpointName(path string) string {
+	if !strings.HasPrefix(path, "/api/v2/") {
+		return "download"
+	}
+	path = strings.Trim(strings.TrimPrefix(path, "/api/v2"), "/")
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i += 2 {
+		segments[i] = ":id"
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,168 @@
+package setup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// newRateLimitedAPI returns a test API that rejects the first rejections requests with 429 Too Many Requests,
+// setting the given headers, and counts every request it receives.
+func newRateLimitedAPI(rejections int32, headers map[string]string, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) <= rejections {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	convey.Convey("Rate limited requests", t, func() {
+		convey.Convey("are retried until the API accepts them", func() {
+			var requests int32
+			api := newRateLimitedAPI(2, map[string]string{"Retry-After": "0"}, &requests)
+			defer api.Close()
+
+			client := &http.Client{Transport: newRateLimitTransport(http.DefaultTransport, "retried", 0, 5)}
+			resp, err := client.Get(api.URL + "/api/v2/workspaces/ws-1")
+			convey.So(err, convey.ShouldBeNil)
+			resp.Body.Close()
+			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+			convey.So(atomic.LoadInt32(&requests), convey.ShouldEqual, 3)
+			convey.So(testutil.ToFloat64(APIRateLimited.WithLabelValues("retried")), convey.ShouldEqual, 2)
+			convey.So(testutil.ToFloat64(APIRequestsTotal.WithLabelValues("retried", "workspaces/:id", "429")), convey.ShouldEqual, 2)
+			convey.So(testutil.ToFloat64(APIRequestsTotal.WithLabelValues("retried", "workspaces/:id", "200")), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("fail once the retries run out", func() {
+			var requests int32
+			api := newRateLimitedAPI(100, map[string]string{"X-RateLimit-Reset": "0"}, &requests)
+			defer api.Close()
+
+			client := &http.Client{Transport: newRateLimitTransport(http.DefaultTransport, "exhausted", 0, 2)}
+			_, err := client.Get(api.URL + "/api/v2/organizations/org-1/workspaces")
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "rate limited by the API, gave up after 2 retries: GET organizations/:id/workspaces")
+			convey.So(atomic.LoadInt32(&requests), convey.ShouldEqual, 3)
+			convey.So(testutil.ToFloat64(APIRateLimited.WithLabelValues("exhausted")), convey.ShouldEqual, 3)
+		})
+
+		convey.Convey("are not retried when their body can not be sent again", func() {
+			var requests int32
+			api := newRateLimitedAPI(100, map[string]string{"Retry-After": "0"}, &requests)
+			defer api.Close()
+
+			req, _ := http.NewRequest(http.MethodPost, api.URL+"/api/v2/runs", nil)
+			req.Body = unrewindableBody{strings.NewReader(`{}`)}
+			_, err := newRateLimitTransport(http.DefaultTransport, "body", 0, 5).RoundTrip(req)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(atomic.LoadInt32(&requests), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("stop waiting when the request is cancelled", func() {
+			var requests int32
+			api := newRateLimitedAPI(100, map[string]string{"Retry-After": "60"}, &requests)
+			defer api.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, api.URL+"/api/v2/ping", nil)
+
+			start := time.Now()
+			_, err := newRateLimitTransport(http.DefaultTransport, "cancelled", 0, 5).RoundTrip(req)
+			convey.So(err == context.DeadlineExceeded, convey.ShouldBeTrue)
+			convey.So(atomic.LoadInt32(&requests), convey.ShouldEqual, 1)
+			convey.So(time.Since(start), convey.ShouldBeLessThan, 5*time.Second)
+		})
+	})
+
+	convey.Convey("Requests are throttled", t, func() {
+		var requests int32
+		api := newRateLimitedAPI(0, nil, &requests)
+		defer api.Close()
+
+		// A limit of 2 requests per second lets 2 requests through at once, the third one waits for half a second.
+		client := &http.Client{Transport: newRateLimitTransport(http.DefaultTransport, "throttled", 2, 0)}
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get(api.URL + "/api/v2/ping")
+			convey.So(err, convey.ShouldBeNil)
+			resp.Body.Close()
+		}
+		convey.So(time.Since(start), convey.ShouldBeGreaterThanOrEqualTo, 400*time.Millisecond)
+
+		// No limit.
+		client = &http.Client{Transport: newRateLimitTransport(http.DefaultTransport, "unthrottled", 0, 0)}
+		start = time.Now()
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get(api.URL + "/api/v2/ping")
+			convey.So(err, convey.ShouldBeNil)
+			resp.Body.Close()
+		}
+		convey.So(time.Since(start), convey.ShouldBeLessThan, 400*time.Millisecond)
+	})
+}
+
+// unrewindableBody is a request body that can not be rewound.
+type unrewindableBody struct {
+	*strings.Reader
+}
+
+func (unrewindableBody) Close() error { return nil }
+
+func TestRetryBackoff(t *testing.T) {
+	response := func(headers map[string]string) *http.Response {
+		resp := &http.Response{Header: http.Header{}}
+		for name, value := range headers {
+			resp.Header.Set(name, value)
+		}
+		return resp
+	}
+
+	convey.Convey("Retry backoff", t, func() {
+		convey.So(retryBackoff(0, response(map[string]string{"Retry-After": "2"})), convey.ShouldEqual, 2*time.Second)
+		convey.So(retryBackoff(0, response(map[string]string{"Retry-After": "0.5"})), convey.ShouldEqual, 500*time.Millisecond)
+
+		date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+		d := retryBackoff(0, response(map[string]string{"Retry-After": date}))
+		convey.So(d, convey.ShouldBeGreaterThan, 8*time.Second)
+		convey.So(d, convey.ShouldBeLessThanOrEqualTo, 10*time.Second)
+
+		past := time.Now().Add(-10 * time.Second).UTC().Format(http.TimeFormat)
+		convey.So(retryBackoff(0, response(map[string]string{"Retry-After": past})), convey.ShouldEqual, 0)
+
+		convey.So(retryBackoff(0, response(map[string]string{"X-RateLimit-Reset": "1.5"})), convey.ShouldEqual, 1500*time.Millisecond)
+		convey.So(retryBackoff(0, response(map[string]string{"Retry-After": "3", "X-RateLimit-Reset": "1"})), convey.ShouldEqual, 3*time.Second)
+
+		convey.So(retryBackoff(0, response(nil)), convey.ShouldEqual, retryBackoffMin)
+		convey.So(retryBackoff(2, response(nil)), convey.ShouldEqual, 4*retryBackoffMin)
+		convey.So(retryBackoff(10, response(nil)), convey.ShouldEqual, retryBackoffMax)
+		convey.So(retryBackoff(100, response(nil)), convey.ShouldEqual, retryBackoffMax)
+		convey.So(retryBackoff(0, response(map[string]string{"Retry-After": "soon"})), convey.ShouldEqual, retryBackoffMin)
+	})
+}
+
+func TestEndpointName(t *testing.T) {
+	convey.Convey("Endpoint labels", t, func() {
+		convey.So(endpointName("/api/v2/ping"), convey.ShouldEqual, "ping")
+		convey.So(endpointName("/api/v2/organizations"), convey.ShouldEqual, "organizations")
+		convey.So(endpointName("/api/v2/organizations/my-org"), convey.ShouldEqual, "organizations/:id")
+		convey.So(endpointName("/api/v2/organizations/my-org/workspaces"), convey.ShouldEqual, "organizations/:id/workspaces")
+		convey.So(endpointName("/api/v2/workspaces/ws-123/runs/"), convey.ShouldEqual, "workspaces/:id/runs")
+		convey.So(endpointName("/api/v2/workspaces/ws-123/current-state-version"), convey.ShouldEqual, "workspaces/:id/current-state-version")
+		convey.So(endpointName("/v1/object/dmF1bHQ6djE6"), convey.ShouldEqual, "download")
+	})
+}
@@ -5,8 +5,6 @@ import (
 	"net/http"
 	"os"
 	"runtime"
-	"strconv"
-	"time"
 
 	"github.com/kaizendorks/terraform-cloud-exporter/internal/collector"
 	"github.com/kaizendorks/terraform-cloud-exporter/internal/setup"
@@ -25,44 +23,22 @@ var (
 	BuildDate string
 )
 
-func newHandler(metrics collector.Metrics, config setup.Config) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Use request context for cancellation when connection gets closed.
-		ctx := r.Context()
-		// If a timeout is configured via the Prometheus header, add it to the context.
-		if v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); v != "" {
-			timeoutSeconds, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				level.Error(config.Logger).Log("msg", "Failed to parse timeout from Prometheus header", "err", err)
-			} else {
-				// Create new timeout context with request context as parent.
-				ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds*float64(time.Second)))
-				defer cancel()
-				// Overwrite request with timeout context.
-				r = r.WithContext(ctx)
-			}
-		}
-
-		registry := prometheus.NewRegistry()
-		registry.MustRegister(collector.New(ctx, config, metrics))
-
-		gatherers := prometheus.Gatherers{
-			prometheus.DefaultGatherer,
-			registry,
-		}
-		// Delegate http serving to Prometheus client library, which will call collector.Collect.
-		h := promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})
-		h.ServeHTTP(w, r)
-	}
-}
-
 func main() {
-	config := setup.NewConfig()
+	config := setup.NewConfig(collector.Collectors())
 	level.Info(config.Logger).Log("msg", "Starting tf_exporter", "version", Version, "revision", Commit)
 	level.Debug(config.Logger).Log("msg", "Build Context", "go", GoVersion, "date", BuildDate)
 
-	handlerFunc := newHandler(collector.NewMetrics(), config)
-	http.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, handlerFunc))
+	var handler http.Handler
+	if config.ScrapeInterval > 0 {
+		cache := collector.NewCache(config, collector.NewMetrics(), config.ScrapeInterval)
+		go cache.Run(context.Background())
+		handler = collector.NewCachedHandler(cache, prometheus.DefaultGatherer)
+		level.Info(config.Logger).Log("msg", "Scraping in the background", "interval", config.ScrapeInterval)
+	} else {
+		handler = collector.NewHandler(collector.NewMetrics(), config, prometheus.DefaultGatherer)
+	}
+	http.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, handler))
+	http.HandleFunc("/probe", collector.NewProbeHandler(config))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
 			<head><title>Terraform Cloud/Enterprise Exporter</title></head>